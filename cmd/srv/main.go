@@ -4,11 +4,27 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"srv.exe.dev/srv"
 )
 
-var flagListenAddr = flag.String("listen", ":8000", "address to listen on")
+var (
+	flagListenAddr     = flag.String("listen", ":8000", "address to listen on")
+	flagSafeMode       = flag.Bool("safe-mode", false, "disable filesystem-path, project folder and git endpoints for shared deployments")
+	flagFFmpegRetries  = flag.Int("ffmpeg-retries", 2, "extra attempts for transient FFmpeg failures")
+	flagCustomFilters  = flag.Bool("allow-custom-filters", false, "let generate-video append user-supplied FFmpeg filter steps")
+	flagProviderLimits = flag.String("provider-concurrency", "", "per-provider image request limits, e.g. dalle=5,stability=2")
+	flagAccessLog      = flag.String("access-log-level", "info", "level request logs are written at (debug, info, warn, error)")
+	flagSourcePath     = flag.String("source-path", "", "git checkout pushed by the GitHub integration (default: the server's own source)")
+	flagCORSOrigins    = flag.String("cors-origins", "", "comma-separated origins allowed to call the API cross-origin, e.g. http://localhost:5173")
+	flagMaxImageMB     = flag.Int64("max-image-download-mb", 50, "largest remote image saved into a project, in MB")
+	flagMaxVideoMB     = flag.Int64("max-video-download-mb", 500, "largest remote video saved into a project, in MB")
+	flagDownloadHosts  = flag.String("download-hosts", "", "comma-separated hosts remote images, videos and audio may be fetched from (default: any public host)")
+	flagPrivateHosts   = flag.Bool("allow-private-downloads", false, "let remote downloads reach loopback and private addresses, for local development")
+	flagShutdown       = flag.Duration("shutdown-timeout", 2*time.Minute, "how long shutdown waits for in-flight renders before stopping them")
+	flagGenerateRate   = flag.String("generate-rate", "30/10", "per-client limit on generation requests as per-minute/burst, or 0 to disable")
+)
 
 func main() {
 	if err := run(); err != nil {
@@ -22,9 +38,33 @@ func run() error {
 	if err != nil {
 		hostname = "unknown"
 	}
-	server, err := srv.New("db.sqlite3", hostname)
+	server, err := srv.New("db.sqlite3", hostname, srv.ParseCORSOrigins(*flagCORSOrigins)...)
 	if err != nil {
 		return fmt.Errorf("create server: %w", err)
 	}
+	if err := server.AccessLogLevel.UnmarshalText([]byte(*flagAccessLog)); err != nil {
+		return fmt.Errorf("-access-log-level: %w", err)
+	}
+	server.SafeMode = *flagSafeMode
+	if *flagSourcePath != "" {
+		server.SourcePath = *flagSourcePath
+	}
+	server.FFmpegRetries = *flagFFmpegRetries
+	server.AllowCustomFilters = *flagCustomFilters
+	server.MaxImageDownload = *flagMaxImageMB << 20
+	server.MaxVideoDownload = *flagMaxVideoMB << 20
+	server.DownloadHosts = srv.ParseDownloadHosts(*flagDownloadHosts)
+	server.AllowPrivateHosts = *flagPrivateHosts
+	server.ShutdownTimeout = *flagShutdown
+	if server.GenerateRateLimit, err = srv.ParseRateLimit(*flagGenerateRate); err != nil {
+		return fmt.Errorf("-generate-rate: %w", err)
+	}
+	if *flagProviderLimits != "" {
+		limits, err := srv.ParseProviderConcurrency(*flagProviderLimits)
+		if err != nil {
+			return fmt.Errorf("-provider-concurrency: %w", err)
+		}
+		server.ProviderConcurrency = limits
+	}
 	return server.Serve(*flagListenAddr)
 }
@@ -8,12 +8,46 @@ import (
 	"time"
 )
 
+type GenerationResult struct {
+	ProjectKey string    `json:"project_key"`
+	Kind       string    `json:"kind"`
+	ItemIndex  int64     `json:"item_index"`
+	Url        string    `json:"url"`
+	Provider   string    `json:"provider"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Job struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	ProjectID string    `json:"project_id"`
+	Status    string    `json:"status"`
+	Completed int64     `json:"completed"`
+	Total     int64     `json:"total"`
+	Items     string    `json:"items"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type Migration struct {
 	MigrationNumber int64     `json:"migration_number"`
 	MigrationName   string    `json:"migration_name"`
 	ExecutedAt      time.Time `json:"executed_at"`
 }
 
+type ProjectTemplate struct {
+	Name          string    `json:"name"`
+	Resolution    string    `json:"resolution"`
+	Fps           int64     `json:"fps"`
+	Codec         string    `json:"codec"`
+	Style         string    `json:"style"`
+	KeyframeCount int64     `json:"keyframe_count"`
+	ImageProvider string    `json:"image_provider"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
 type Visitor struct {
 	ID        string    `json:"id"`
 	ViewCount int64     `json:"view_count"`
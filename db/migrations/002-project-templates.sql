@@ -0,0 +1,18 @@
+-- Project templates (reusable presets applied at project creation)
+CREATE TABLE IF NOT EXISTS project_templates (
+    name TEXT PRIMARY KEY,
+    resolution TEXT NOT NULL DEFAULT '',
+    fps INTEGER NOT NULL DEFAULT 0,
+    codec TEXT NOT NULL DEFAULT '',
+    style TEXT NOT NULL DEFAULT '',
+    keyframe_count INTEGER NOT NULL DEFAULT 0,
+    image_provider TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Record execution of this migration
+INSERT
+OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES
+    (002, '002-project-templates');
@@ -0,0 +1,17 @@
+-- Generated media URLs, recorded as soon as a provider returns them so a
+-- crash or navigation-away before the client saves doesn't lose them
+CREATE TABLE IF NOT EXISTS generation_results (
+    project_key TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    item_index INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    provider TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (project_key, kind, item_index)
+);
+
+-- Record execution of this migration
+INSERT
+OR IGNORE INTO migrations (migration_number, migration_name)
+VALUES
+    (003, '003-generation-results');
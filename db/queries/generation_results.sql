@@ -0,0 +1,33 @@
+-- name: UpsertGenerationResult :exec
+INSERT INTO
+  generation_results (
+    project_key,
+    kind,
+    item_index,
+    url,
+    provider,
+    created_at
+  )
+VALUES
+  (?, ?, ?, ?, ?, ?) ON CONFLICT (project_key, kind, item_index) DO
+UPDATE
+SET
+  url = excluded.url,
+  provider = excluded.provider,
+  created_at = excluded.created_at;
+
+-- name: ListGenerationResults :many
+SELECT
+  *
+FROM
+  generation_results
+WHERE
+  project_key = ?
+ORDER BY
+  kind,
+  item_index;
+
+-- name: DeleteGenerationResults :exec
+DELETE FROM generation_results
+WHERE
+  project_key = ?;
@@ -0,0 +1,37 @@
+-- name: UpsertJob :exec
+INSERT INTO
+  jobs (
+    id,
+    kind,
+    project_id,
+    status,
+    completed,
+    total,
+    items,
+    error,
+    created_at,
+    updated_at
+  )
+VALUES
+  (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO
+UPDATE
+SET
+  status = excluded.status,
+  completed = excluded.completed,
+  total = excluded.total,
+  items = excluded.items,
+  error = excluded.error,
+  updated_at = excluded.updated_at;
+
+-- name: ListJobs :many
+SELECT
+  *
+FROM
+  jobs
+ORDER BY
+  created_at;
+
+-- name: DeleteJobsUpdatedBefore :exec
+DELETE FROM jobs
+WHERE
+  updated_at < ?;
@@ -0,0 +1,40 @@
+-- name: UpsertProjectTemplate :exec
+INSERT INTO
+  project_templates (
+    name,
+    resolution,
+    fps,
+    codec,
+    style,
+    keyframe_count,
+    image_provider,
+    created_at,
+    updated_at
+  )
+VALUES
+  (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (name) DO
+UPDATE
+SET
+  resolution = excluded.resolution,
+  fps = excluded.fps,
+  codec = excluded.codec,
+  style = excluded.style,
+  keyframe_count = excluded.keyframe_count,
+  image_provider = excluded.image_provider,
+  updated_at = excluded.updated_at;
+
+-- name: ProjectTemplateWithName :one
+SELECT
+  *
+FROM
+  project_templates
+WHERE
+  name = ?;
+
+-- name: ListProjectTemplates :many
+SELECT
+  *
+FROM
+  project_templates
+ORDER BY
+  name;
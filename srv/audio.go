@@ -0,0 +1,351 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DuckingOptions configures sidechain ducking, which lowers background music
+// while narration is playing. It only has an effect when the render has both
+// a narration track and a music track; otherwise it is ignored.
+type DuckingOptions struct {
+	Enabled bool `json:"enabled"`
+	// Threshold is the narration level (linear, 0-1) above which the music
+	// is compressed
+	Threshold float64 `json:"threshold,omitempty"`
+	// Ratio is how strongly the music is reduced once narration crosses the
+	// threshold (1-20)
+	Ratio float64 `json:"ratio,omitempty"`
+	// AttackMs and ReleaseMs control how fast the music dips and recovers
+	AttackMs  float64 `json:"attackMs,omitempty"`
+	ReleaseMs float64 `json:"releaseMs,omitempty"`
+}
+
+// Defaults for sidechain ducking: a clear but not total dip under speech.
+const (
+	defaultDuckThreshold = 0.05
+	defaultDuckRatio     = 8
+	defaultDuckAttackMs  = 20
+	defaultDuckReleaseMs = 250
+)
+
+// withDefaults fills unset options and validates them against the ranges
+// FFmpeg's sidechaincompress accepts.
+func (d DuckingOptions) withDefaults() (DuckingOptions, error) {
+	if d.Threshold == 0 {
+		d.Threshold = defaultDuckThreshold
+	}
+	if d.Ratio == 0 {
+		d.Ratio = defaultDuckRatio
+	}
+	if d.AttackMs == 0 {
+		d.AttackMs = defaultDuckAttackMs
+	}
+	if d.ReleaseMs == 0 {
+		d.ReleaseMs = defaultDuckReleaseMs
+	}
+
+	switch {
+	case d.Threshold < 0.000976563 || d.Threshold > 1:
+		return d, errors.New("ducking threshold must be between 0.001 and 1")
+	case d.Ratio < 1 || d.Ratio > 20:
+		return d, errors.New("ducking ratio must be between 1 and 20")
+	case d.AttackMs < 0.01 || d.AttackMs > 2000:
+		return d, errors.New("ducking attackMs must be between 0.01 and 2000")
+	case d.ReleaseMs < 0.01 || d.ReleaseMs > 9000:
+		return d, errors.New("ducking releaseMs must be between 0.01 and 9000")
+	}
+	return d, nil
+}
+
+// audioMixFilter builds the filter_complex graph mixing the narration and
+// music streams (input specifiers like "0:a" or filter labels) into [aout].
+// With ducking enabled the narration also drives a sidechain compressor on
+// the music, so the music quiets during speech.
+func audioMixFilter(narration, music string, ducking *DuckingOptions) (string, error) {
+	if ducking == nil || !ducking.Enabled {
+		return fmt.Sprintf("[%s][%s]amix=inputs=2:duration=first:normalize=0[aout]", narration, music), nil
+	}
+
+	d, err := ducking.withDefaults()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(
+		"[%s]asplit=2[voice][sidechain];"+
+			"[%s][sidechain]sidechaincompress=threshold=%g:ratio=%g:attack=%g:release=%g[ducked];"+
+			"[voice][ducked]amix=inputs=2:duration=first:normalize=0[aout]",
+		narration, music, d.Threshold, d.Ratio, d.AttackMs, d.ReleaseMs,
+	), nil
+}
+
+// audioBedFade is how long the music bed fades in and out, in seconds.
+const audioBedFade = 2.0
+
+// audioExtensions lists the music bed formats we accept.
+var audioExtensions = []string{".mp3", ".m4a", ".aac", ".wav", ".ogg", ".flac"}
+
+// findAudioBed returns the path of the project's music bed, stored as
+// audio/bed.<ext>, or "" if it has none.
+func findAudioBed(projectPath string) string {
+	for _, ext := range audioExtensions {
+		bed := filepath.Join(projectPath, "audio", "bed"+ext)
+		if _, err := os.Stat(bed); err == nil {
+			return bed
+		}
+	}
+	return ""
+}
+
+// audioBedInputArgs adds the music bed as an input that loops forever; the
+// filter from audioBedFilter trims it to the movie's length.
+func audioBedInputArgs(path string) []string {
+	return []string{"-stream_loop", "-1", "-i", path}
+}
+
+// audioBedFilter trims a looping bed input to duration seconds with a fade at
+// each end, scaled by volume (0 or 1 leaves it as is), labelled [label] so it
+// can be mixed under the scene audio.
+func audioBedFilter(input int, duration, fade, volume float64, label string) string {
+	filter := fmt.Sprintf("[%d:a]atrim=duration=%g,asetpts=PTS-STARTPTS,afade=t=in:st=0:d=%g,afade=t=out:st=%g:d=%g",
+		input, duration, fade, math.Max(0, duration-fade), fade)
+	if volume > 0 && volume != 1 {
+		filter += fmt.Sprintf(",volume=%g", volume)
+	}
+	return filter + "[" + label + "]"
+}
+
+// maxMusicVolume is the loudest gain a music bed may be given.
+const maxMusicVolume = 2.0
+
+// audioBedProjectDir returns the folder a project's music bed goes in.
+// Projects that only exist in memory get their folder on first upload.
+func (s *Server) audioBedProjectDir(projectID string) (string, bool) {
+	projectPath, err := s.projectDir(projectID)
+	if err == nil {
+		return projectPath, true
+	}
+	s.mu.RLock()
+	_, exists := s.projects[projectID]
+	s.mu.RUnlock()
+	if !exists {
+		return "", false
+	}
+	return filepath.Join(s.ProjectsRoot, projectID), true
+}
+
+// saveAudioBed stores src as the project's music bed with the given
+// extension, replacing any previous bed, and returns its size.
+func (s *Server) saveAudioBed(projectID, projectPath, ext string, src io.Reader) (int64, error) {
+	audioDir := filepath.Join(projectPath, "audio")
+	if err := os.MkdirAll(audioDir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create audio directory: %w", err)
+	}
+
+	lock := s.projectLock(projectPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	bedPath := filepath.Join(audioDir, "bed"+ext)
+	size, err := writeFileAtomic(bedPath, src, 0644)
+	if err != nil {
+		return 0, err
+	}
+	// Only one bed per project, whatever its format
+	for _, other := range audioExtensions {
+		if other != ext {
+			os.Remove(filepath.Join(audioDir, "bed"+other))
+		}
+	}
+
+	s.mu.Lock()
+	if project, ok := s.projects[projectID]; ok {
+		project.AudioBed = "bed" + ext
+	}
+	s.mu.Unlock()
+
+	slog.Info("saved audio bed", "project", projectID, "path", bedPath, "size", size)
+	return size, nil
+}
+
+// audioContentTypes maps the audio MIME types we accept from a URL to the
+// bed's extension, for URLs whose path doesn't name one.
+var audioContentTypes = map[string]string{
+	"audio/mpeg":   ".mp3",
+	"audio/mp3":    ".mp3",
+	"audio/mp4":    ".m4a",
+	"audio/x-m4a":  ".m4a",
+	"audio/aac":    ".aac",
+	"audio/wav":    ".wav",
+	"audio/x-wav":  ".wav",
+	"audio/wave":   ".wav",
+	"audio/ogg":    ".ogg",
+	"audio/flac":   ".flac",
+	"audio/x-flac": ".flac",
+}
+
+// fetchAudio downloads a music track from rawURL, at most limit bytes, and
+// works out its extension from the URL path or Content-Type.
+func (s *Server) fetchAudio(ctx context.Context, rawURL string, limit int64) (io.ReadCloser, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, "", fmt.Errorf("%q is not an http(s) URL", rawURL)
+	}
+	resp, err := s.httpGet(ctx, rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ext := strings.ToLower(path.Ext(u.Path))
+	if !slices.Contains(audioExtensions, ext) {
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		var ok bool
+		if ext, ok = audioContentTypes[mediaType]; !ok {
+			resp.Body.Close()
+			return nil, "", fmt.Errorf("unsupported audio type %q (supported: %s)", mediaType, strings.Join(audioExtensions, ", "))
+		}
+	}
+	return http.MaxBytesReader(nil, resp.Body, limit), ext, nil
+}
+
+// HandleUploadAudioBed sets the music bed under the whole movie, replacing
+// any previous one. The multipart form carries either an "audio" file or a
+// "url" to fetch it from, plus optional "volume" (gain, 0-2, where 1 leaves
+// the track as is) and "duck" (lower the music under the scene audio)
+// fields. Settings can be changed without a new track. It is served at both
+// /audio and /music.
+func (s *Server) HandleUploadAudioBed(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	projectPath, ok := s.audioBedProjectDir(projectID)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	if !parseUpload(w, r, s.MaxUploadSize) {
+		return
+	}
+
+	volume := -1.0
+	if v := r.FormValue("volume"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > maxMusicVolume {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("volume must be a number between 0 and %g", maxMusicVolume))
+			return
+		}
+		volume = f
+	}
+	var duck *bool
+	if v := r.FormValue("duck"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "duck must be true or false")
+			return
+		}
+		duck = &b
+	}
+
+	var src io.ReadCloser
+	var ext string
+	if file, header, err := r.FormFile("audio"); err == nil {
+		src = file
+		ext = strings.ToLower(filepath.Ext(header.Filename))
+		if !slices.Contains(audioExtensions, ext) {
+			file.Close()
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported audio format %q (supported: %s)", ext, strings.Join(audioExtensions, ", ")))
+			return
+		}
+	} else if musicURL := r.FormValue("url"); musicURL != "" {
+		src, ext, err = s.fetchAudio(r.Context(), musicURL, s.MaxUploadSize)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Failed to fetch music: "+err.Error())
+			return
+		}
+	} else if volume < 0 && duck == nil {
+		writeJSONError(w, http.StatusBadRequest, "An audio file, url, volume or duck setting is required")
+		return
+	}
+
+	resp := map[string]any{"success": true}
+	if src != nil {
+		size, err := s.saveAudioBed(projectID, projectPath, ext, src)
+		src.Close()
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Music exceeds the %d byte (%d MB) limit", s.MaxUploadSize, s.MaxUploadSize>>20))
+				return
+			}
+			writeJSONError(w, http.StatusInternalServerError, "Failed to save music: "+err.Error())
+			return
+		}
+		resp["audioBed"] = "bed" + ext
+		resp["size"] = size
+	}
+
+	s.mu.Lock()
+	if project, ok := s.projects[projectID]; ok {
+		if volume >= 0 {
+			project.MusicVolume = volume
+		}
+		if duck != nil {
+			if project.Ducking == nil {
+				project.Ducking = &DuckingOptions{}
+			}
+			project.Ducking.Enabled = *duck
+		}
+		resp["volume"] = project.MusicVolume
+		resp["duck"] = project.Ducking != nil && project.Ducking.Enabled
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// validateNarrationTiming checks that narration offsets leave room for the
+// narration inside a clip of the given duration.
+func validateNarrationTiming(start, padding, clipDuration float64) error {
+	switch {
+	case start < 0 || padding < 0:
+		return errors.New("narrationStart and narrationPadding must not be negative")
+	case start+padding >= clipDuration:
+		return fmt.Errorf("narrationStart (%gs) plus narrationPadding (%gs) must be less than the clip duration (%gs)", start, padding, clipDuration)
+	}
+	return nil
+}
+
+// narrationFilter offsets a narration input within its clip: adelay holds it
+// back start seconds after the cut and apad appends padding seconds of
+// silence so it finishes before the scene ends. The result is labelled
+// [label].
+func narrationFilter(input int, start, padding, clipDuration float64, label string) (string, error) {
+	if err := validateNarrationTiming(start, padding, clipDuration); err != nil {
+		return "", err
+	}
+	filter := fmt.Sprintf("[%d:a]", input)
+	steps := []string{}
+	if start > 0 {
+		steps = append(steps, fmt.Sprintf("adelay=delays=%d:all=1", int(math.Round(start*1000))))
+	}
+	if padding > 0 {
+		steps = append(steps, fmt.Sprintf("apad=pad_dur=%g", padding))
+	}
+	if len(steps) == 0 {
+		steps = append(steps, "anull")
+	}
+	return filter + strings.Join(steps, ",") + "[" + label + "]", nil
+}
@@ -0,0 +1,118 @@
+package srv
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// CaptionOptions turns scene narration into captions on the final render.
+// Mode "burn" (the default) draws them into the picture; "sidecar" writes a
+// separate final.srt instead, for platforms that take caption tracks.
+type CaptionOptions struct {
+	Mode string `json:"mode,omitempty"`
+	// FontSize is in libass units, relative to a 288-line frame
+	FontSize int `json:"fontSize,omitempty"`
+	// Color is "#RRGGBB" or one of the captionColors names
+	Color string `json:"color,omitempty"`
+	// Position is "bottom", "top" or "middle"
+	Position string `json:"position,omitempty"`
+}
+
+// Caption defaults: white text near the bottom, readable at any frame size.
+const (
+	defaultCaptionMode     = "burn"
+	defaultCaptionFontSize = 18
+	defaultCaptionColor    = "#FFFFFF"
+	defaultCaptionPosition = "bottom"
+)
+
+// captionColors are the color names accepted besides "#RRGGBB".
+var captionColors = map[string]string{
+	"white":  "#FFFFFF",
+	"black":  "#000000",
+	"yellow": "#FFFF00",
+	"red":    "#FF0000",
+	"green":  "#00FF00",
+	"blue":   "#0000FF",
+}
+
+// captionAlignments maps positions to ASS numpad alignments.
+var captionAlignments = map[string]int{"bottom": 2, "middle": 5, "top": 8}
+
+var hexColor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// withDefaults fills unset options and validates them.
+func (c CaptionOptions) withDefaults() (CaptionOptions, error) {
+	if c.Mode == "" {
+		c.Mode = defaultCaptionMode
+	}
+	if c.FontSize == 0 {
+		c.FontSize = defaultCaptionFontSize
+	}
+	if c.Color == "" {
+		c.Color = defaultCaptionColor
+	}
+	if named, ok := captionColors[strings.ToLower(c.Color)]; ok {
+		c.Color = named
+	}
+	if c.Position == "" {
+		c.Position = defaultCaptionPosition
+	}
+
+	switch {
+	case !slices.Contains([]string{"burn", "sidecar"}, c.Mode):
+		return c, fmt.Errorf("caption mode must be burn or sidecar, got %q", c.Mode)
+	case c.FontSize < 6 || c.FontSize > 96:
+		return c, fmt.Errorf("caption fontSize must be between 6 and 96, got %d", c.FontSize)
+	case !hexColor.MatchString(c.Color):
+		return c, fmt.Errorf("caption color must be #RRGGBB or a color name, got %q", c.Color)
+	case captionAlignments[c.Position] == 0:
+		return c, fmt.Errorf("caption position must be bottom, middle or top, got %q", c.Position)
+	}
+	return c, nil
+}
+
+// forceStyle is the subtitles filter's force_style value for resolved
+// options. ASS colors are &HBBGGRR.
+func (c CaptionOptions) forceStyle() string {
+	rgb := strings.ToUpper(c.Color[1:])
+	return fmt.Sprintf("FontSize=%d,PrimaryColour=&H00%s%s%s,Alignment=%d,MarginV=20",
+		c.FontSize, rgb[4:6], rgb[2:4], rgb[0:2], captionAlignments[c.Position])
+}
+
+// srtTimestamp formats seconds as an SRT timestamp, 00:01:02,500.
+func srtTimestamp(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// buildSRT writes one caption per planned clip with narration, shown for the
+// whole clip. It returns "" when no clip has narration.
+func buildSRT(clips []PlannedClip) string {
+	var b strings.Builder
+	n := 0
+	for _, clip := range clips {
+		text := strings.TrimSpace(clip.Narration)
+		if text == "" {
+			continue
+		}
+		n++
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", n, srtTimestamp(clip.Start), srtTimestamp(clip.Start+clip.Duration), text)
+	}
+	return b.String()
+}
+
+// escapeFilterValue escapes a filter option value for use inside a
+// filtergraph: once for the option parser, then again for the graph.
+func escapeFilterValue(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `:`, `\:`).Replace(value)
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `[`, `\[`, `]`, `\]`, `,`, `\,`, `;`, `\;`).Replace(value)
+}
+
+// subtitlesFilter burns the SRT at path into the video with the caption style.
+func subtitlesFilter(path string, captions CaptionOptions) string {
+	return fmt.Sprintf("subtitles=filename=%s:force_style=%s", escapeFilterValue(path), escapeFilterValue(captions.forceStyle()))
+}
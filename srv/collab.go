@@ -0,0 +1,172 @@
+package srv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// collabSendBuffer is how many events may queue for one client; a client
+// that falls further behind is disconnected rather than slowing everyone.
+const collabSendBuffer = 32
+
+// collabWriteTimeout bounds sending one event to a client.
+const collabWriteTimeout = 10 * time.Second
+
+// ProjectEvent is one storyboard change pushed to a project's WebSocket
+// clients, small enough to apply incrementally.
+type ProjectEvent struct {
+	// Type is "scene" for an edit, "scene-image" for a finished
+	// regeneration, "scenes" when scenes are reordered, added or removed,
+	// or "job" for a job status change
+	Type    string `json:"type"`
+	SceneID string `json:"sceneId,omitempty"`
+	Scene   *Scene `json:"scene,omitempty"`
+	// Scenes is the whole new scene list of a "scenes" event
+	Scenes   []Scene   `json:"scenes,omitempty"`
+	ImageURL string    `json:"imageUrl,omitempty"`
+	Job      *JobEvent `json:"job,omitempty"`
+}
+
+// JobEvent is the part of a Job a storyboard needs to show its progress.
+type JobEvent struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Status    JobStatus `json:"status"`
+	Completed int       `json:"completed"`
+	Total     int       `json:"total"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// collabConn is one connected storyboard client. Events are queued on send
+// and written by the connection's own goroutine.
+type collabConn struct {
+	ws   *websocket.Conn
+	send chan []byte
+}
+
+// HandleProjectWebSocket streams a project's ProjectEvents to the client
+// until it disconnects. Anything the client sends is ignored.
+func (s *Server) HandleProjectWebSocket(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	s.mu.RLock()
+	_, exists := s.projects[projectID]
+	s.mu.RUnlock()
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	websocket.Server{
+		Handshake: s.checkWebSocketOrigin,
+		Handler: func(ws *websocket.Conn) {
+			conn := &collabConn{ws: ws, send: make(chan []byte, collabSendBuffer)}
+			s.addCollabConn(projectID, conn)
+			defer s.removeCollabConn(projectID, conn)
+			go conn.writeEvents()
+			// Reading only notices the client going away
+			io.Copy(io.Discard, ws)
+		},
+	}.ServeHTTP(w, r)
+}
+
+// checkWebSocketOrigin admits browsers on this host or in CORSOrigins, and
+// clients that send no Origin, so other sites can't listen in through a
+// visitor's browser.
+func (s *Server) checkWebSocketOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if u.Host == r.Host || slices.Contains(s.CORSOrigins, origin) || slices.Contains(s.CORSOrigins, "*") {
+		return nil
+	}
+	return fmt.Errorf("origin %q is not allowed", origin)
+}
+
+// writeEvents sends queued events until the queue is closed or a write
+// fails; closing the socket then ends the handler's read loop.
+func (c *collabConn) writeEvents() {
+	defer c.ws.Close()
+	for data := range c.send {
+		c.ws.SetWriteDeadline(time.Now().Add(collabWriteTimeout))
+		if err := websocket.Message.Send(c.ws, string(data)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) addCollabConn(projectID string, conn *collabConn) {
+	s.collabMu.Lock()
+	defer s.collabMu.Unlock()
+	s.collabConns[projectID] = append(s.collabConns[projectID], conn)
+}
+
+func (s *Server) removeCollabConn(projectID string, conn *collabConn) {
+	s.collabMu.Lock()
+	defer s.collabMu.Unlock()
+	s.dropCollabConnLocked(projectID, conn)
+}
+
+// dropCollabConnLocked unregisters conn and closes its queue, if it is still
+// registered. The caller holds collabMu.
+func (s *Server) dropCollabConnLocked(projectID string, conn *collabConn) {
+	conns := s.collabConns[projectID]
+	i := slices.Index(conns, conn)
+	if i < 0 {
+		return
+	}
+	close(conn.send)
+	if conns = slices.Delete(conns, i, i+1); len(conns) == 0 {
+		delete(s.collabConns, projectID)
+	} else {
+		s.collabConns[projectID] = conns
+	}
+}
+
+// broadcast queues event for every client of the project. A client whose
+// queue is full is dropped; it can reconnect and reload the project.
+func (s *Server) broadcast(projectID string, event ProjectEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("failed to encode project event", "project", projectID, "error", err)
+		return
+	}
+	s.collabMu.Lock()
+	defer s.collabMu.Unlock()
+	for _, conn := range slices.Clone(s.collabConns[projectID]) {
+		select {
+		case conn.send <- data:
+		default:
+			slog.Warn("dropping slow storyboard client", "project", projectID)
+			s.dropCollabConnLocked(projectID, conn)
+		}
+	}
+}
+
+// broadcastJob tells the job's project about its current status.
+func (s *Server) broadcastJob(jobID string) {
+	job, ok := s.getJob(jobID)
+	if !ok || job.ProjectID == "" {
+		return
+	}
+	s.broadcast(job.ProjectID, ProjectEvent{Type: "job", Job: &JobEvent{
+		ID:        job.ID,
+		Kind:      job.Kind,
+		Status:    job.Status,
+		Completed: job.Completed,
+		Total:     job.Total,
+		Error:     job.Error,
+	}})
+}
@@ -0,0 +1,60 @@
+package srv
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsAllowedMethods and corsAllowedHeaders cover the JSON and multipart
+// API endpoints; multipart uploads need no extra headers beyond Content-Type.
+const (
+	corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowedHeaders = "Content-Type, Authorization, If-Match, If-None-Match"
+	corsMaxAge         = "600"
+)
+
+// cors lets the origins in CORSOrigins call the API from the browser,
+// answering preflight OPTIONS requests itself. With no origins configured it
+// adds nothing, leaving the API same-origin only.
+func (s *Server) cors(next http.Handler) http.Handler {
+	if len(s.CORSOrigins) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && (slices.Contains(s.CORSOrigins, origin) || slices.Contains(s.CORSOrigins, "*"))
+		if origin != "" {
+			// Responses differ by Origin, so caches must key on it
+			w.Header().Add("Vary", "Origin")
+		}
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "ETag, Content-Disposition")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+				w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ParseCORSOrigins splits a comma-separated origin list, e.g.
+// "http://localhost:5173,https://app.example.com", dropping blanks and
+// trailing slashes so entries match the Origin header exactly.
+func ParseCORSOrigins(list string) []string {
+	var origins []string
+	for _, origin := range strings.Split(list, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
@@ -0,0 +1,102 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const dalleEndpoint = "https://api.openai.com/v1/images/generations"
+
+// DalleProvider generates images with OpenAI's DALL-E 3.
+type DalleProvider struct {
+	APIKey string
+	// Endpoint overrides the OpenAI images URL (for tests)
+	Endpoint string
+	// Size is one of DALL-E 3's sizes; defaults to 1024x1024
+	Size string
+	// ResponseFormat is "url" (default) or "b64_json", which is returned as a
+	// data URL so it can be saved without a second download
+	ResponseFormat string
+	Client         *http.Client
+}
+
+func (p *DalleProvider) Name() string { return "dalle" }
+
+func (p *DalleProvider) Generate(ctx context.Context, prompt string, opts GenOptions) (string, error) {
+	if p.APIKey == "" {
+		return "", errors.New("OPENAI_API_KEY is not set")
+	}
+
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = dalleEndpoint
+	}
+	size := p.Size
+	if size == "" {
+		size = "1024x1024"
+	}
+	format := p.ResponseFormat
+	if format == "" {
+		format = "url"
+	}
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 120 * time.Second}
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"model":           "dall-e-3",
+		"prompt":          prompt,
+		"n":               1,
+		"size":            size,
+		"response_format": format,
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("dalle request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data []struct {
+			URL     string `json:"url"`
+			B64JSON string `json:"b64_json"`
+		} `json:"data"`
+		Error *struct {
+			Message string `json:"message"`
+			Code    string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 32<<20)).Decode(&result); err != nil {
+		return "", fmt.Errorf("dalle response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if result.Error != nil {
+			return "", fmt.Errorf("dalle: %s (status %d)", result.Error.Message, resp.StatusCode)
+		}
+		return "", fmt.Errorf("dalle: status %d", resp.StatusCode)
+	}
+	if len(result.Data) == 0 {
+		return "", errors.New("dalle: no image returned")
+	}
+
+	if img := result.Data[0]; img.B64JSON != "" {
+		return "data:image/png;base64," + img.B64JSON, nil
+	} else if img.URL != "" {
+		return img.URL, nil
+	}
+	return "", errors.New("dalle: empty image in response")
+}
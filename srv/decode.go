@@ -0,0 +1,123 @@
+package srv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	// maxJSONBody bounds requests that only carry settings and text.
+	maxJSONBody = 1 << 20
+	// maxMediaJSONBody bounds requests that may embed images or videos as
+	// base64 data URLs (project saves, keyframes, clips).
+	maxMediaJSONBody = 1 << 30
+	// maxErrorDetail caps how much of a decode error is echoed to the client,
+	// so a malformed giant body can't be reflected back.
+	maxErrorDetail = 200
+	// defaultMaxUploadSize bounds each uploaded file unless the server
+	// overrides it.
+	defaultMaxUploadSize = 500 << 20
+	// multipartOverhead allows for form fields and part headers on top of
+	// the file size limit.
+	multipartOverhead = 1 << 20
+	// multipartMemory is how much of a form is buffered in memory; larger
+	// files spill to temp files.
+	multipartMemory = 32 << 20
+)
+
+// writeJSONError responds with code and a {"error", "code"} JSON body, so
+// clients parse failures the same way as successes. Like http.Error, it
+// expects nothing else to have been written to w.
+func writeJSONError(w http.ResponseWriter, code int, msg string) {
+	writeJSONErrorFields(w, code, msg, nil)
+}
+
+// writeJSONErrorFields is writeJSONError with extra fields in the body, such
+// as the current version on a save conflict.
+func writeJSONErrorFields(w http.ResponseWriter, code int, msg string, fields map[string]any) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	body := map[string]any{
+		"error": msg,
+		"code":  code,
+	}
+	for k, v := range fields {
+		body[k] = v
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// decodeJSON decodes the request body into v, reading at most limit bytes.
+// On failure it writes a 400 (or 413 for an oversized body) with a short,
+// classified message and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any, limit int64) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	status := http.StatusBadRequest
+	msg := jsonErrorMessage(err)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		status = http.StatusRequestEntityTooLarge
+		msg = fmt.Sprintf("Request body exceeds %d bytes", maxBytesErr.Limit)
+	}
+	writeJSONError(w, status, msg)
+	return false
+}
+
+// jsonErrorMessage turns a decode error into a friendly, bounded message.
+func jsonErrorMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "Invalid request: body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "Invalid request: JSON ends unexpectedly"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("Invalid request: malformed JSON at byte %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return fmt.Sprintf("Invalid request: field %q must be %s, not %s", truncate(typeErr.Field, maxErrorDetail), typeErr.Type, typeErr.Value)
+		}
+		return fmt.Sprintf("Invalid request: expected %s, not %s", typeErr.Type, typeErr.Value)
+	default:
+		return "Invalid request: " + truncate(err.Error(), maxErrorDetail)
+	}
+}
+
+// parseUpload parses a multipart form whose files may each be at most limit
+// bytes. The body is capped so an oversized upload is cut off rather than
+// buffered. On failure it writes a 400 (or 413 stating the limit) and
+// returns false.
+func parseUpload(w http.ResponseWriter, r *http.Request, limit int64) bool {
+	tooLarge := fmt.Sprintf("Upload exceeds the %d byte (%d MB) limit", limit, limit>>20)
+	r.Body = http.MaxBytesReader(w, r.Body, limit+multipartOverhead)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, tooLarge)
+			return false
+		}
+		writeJSONError(w, http.StatusBadRequest, "Failed to parse form: "+err.Error())
+		return false
+	}
+	for _, files := range r.MultipartForm.File {
+		for _, file := range files {
+			if file.Size > limit {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, tooLarge)
+				return false
+			}
+		}
+	}
+	return true
+}
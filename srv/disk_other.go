@@ -0,0 +1,10 @@
+//go:build !unix
+
+package srv
+
+import "errors"
+
+// diskFree is unsupported without statfs.
+func diskFree(path string) (uint64, error) {
+	return 0, errors.New("disk space reporting is not supported on this platform")
+}
@@ -0,0 +1,295 @@
+package srv
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultHTTPTimeout bounds a whole outbound request, body included.
+	defaultHTTPTimeout = 2 * time.Minute
+	// httpResponseHeaderTimeout bounds the wait for a remote to start
+	// answering, so a stalled host fails well before the overall timeout.
+	httpResponseHeaderTimeout = 30 * time.Second
+
+	// defaultMaxImageDownload and defaultMaxVideoDownload bound remote
+	// images and videos unless the server overrides them.
+	defaultMaxImageDownload = 50 << 20
+	defaultMaxVideoDownload = 500 << 20
+)
+
+// errDownloadTooLarge is returned when a remote file exceeds its size limit.
+var errDownloadTooLarge = errors.New("download exceeds the size limit")
+
+// newHTTPClient is the client shared by every outbound call: providers,
+// GitHub and remote images and videos. Its transport pools connections per
+// host; callers pass their request's context so a disconnect cancels them.
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 8
+	transport.ResponseHeaderTimeout = httpResponseHeaderTimeout
+	return &http.Client{Timeout: defaultHTTPTimeout, Transport: transport}
+}
+
+// errBlockedURL is returned for download URLs that validateDownloadURL
+// refuses, and for connections the download client won't make.
+var errBlockedURL = errors.New("download URL not allowed")
+
+// cgnatPrefix is the carrier-grade NAT range, which netip doesn't count as
+// private but which is just as internal.
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// isInternalAddr reports whether addr is loopback, private, link-local (the
+// cloud metadata endpoints live there) or otherwise not a public unicast
+// address.
+func isInternalAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() ||
+		addr.IsUnspecified() || cgnatPrefix.Contains(addr)
+}
+
+// newDownloadClient is the client for fetching client-supplied URLs. Like
+// newHTTPClient's, but it refuses to connect to internal addresses unless
+// allowPrivate says otherwise. The check is made on the address actually
+// dialed, after DNS resolution, so a host that resolves to a public address
+// when checked and an internal one when dialed (DNS rebinding) is still
+// caught. It never uses a proxy, since the check would see only the proxy.
+func newDownloadClient(allowPrivate func() bool) *http.Client {
+	client := newHTTPClient()
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if allowPrivate() {
+				return nil
+			}
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %v", errBlockedURL, err)
+			}
+			if isInternalAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s is an internal address", errBlockedURL, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport := client.Transport.(*http.Transport)
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return client
+}
+
+// validateDownloadURL checks a client-supplied URL before it is fetched:
+// only http(s) is allowed, and the host must be in DownloadHosts when that
+// is set. Internal addresses are refused by the download client when it
+// dials (see newDownloadClient).
+func (s *Server) validateDownloadURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBlockedURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q is not http or https", errBlockedURL, u.Scheme)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return fmt.Errorf("%w: %s has no host", errBlockedURL, redactURL(rawURL))
+	}
+	if len(s.DownloadHosts) > 0 && !hostAllowed(host, s.DownloadHosts) {
+		return fmt.Errorf("%w: host %s is not in the download allowlist", errBlockedURL, host)
+	}
+	return nil
+}
+
+// hostAllowed reports whether host is one of the allowed hosts or a subdomain
+// of one.
+func hostAllowed(host string, allowed []string) bool {
+	for _, a := range allowed {
+		if host == a || strings.HasSuffix(host, "."+a) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDownloadHosts parses a comma-separated host allowlist like
+// "cdn.example.com,images.example.org".
+func ParseDownloadHosts(list string) []string {
+	var hosts []string
+	for _, host := range strings.Split(list, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// httpGet fetches a client-supplied URL with the download client, failing
+// on any status but 200. The URL and every redirect it takes must pass
+// validateDownloadURL. The caller closes the body.
+func (s *Server) httpGet(ctx context.Context, rawURL string) (*http.Response, error) {
+	if err := s.validateDownloadURL(rawURL); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := *s.downloadClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return s.validateDownloadURL(req.URL.String())
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetch %s: status %d", redactURL(rawURL), resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// redactURL drops the query string, which may carry signed tokens, from a
+// URL before it's logged or returned.
+func redactURL(rawURL string) string {
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
+
+// downloadImage saves an image from a data URL, /static/ path or remote URL
+// to destPath.
+func (s *Server) downloadImage(ctx context.Context, url, destPath string) error {
+	// Images of loaded projects are published under /static/
+	if srcPath, ok := s.staticFilePath(url); ok {
+		src, err := os.Open(srcPath)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = writeFileAtomic(destPath, src, 0644)
+		return err
+	}
+
+	// Handle base64 data URLs
+	// Frames keep destPath whatever their format; FFmpeg sniffs the content
+	if strings.HasPrefix(url, "data:image") {
+		_, data, err := decodeDataURL(url)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(destPath, data, 0644)
+	}
+
+	// Handle regular URLs
+	resp, err := s.httpGet(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return saveDownload(resp, destPath, "image", s.MaxImageDownload)
+}
+
+// downloadVideo saves a video from /static/videos or a remote URL to
+// destPath.
+func (s *Server) downloadVideo(ctx context.Context, videoURL, destPath string) error {
+	var srcPath string
+
+	if strings.HasPrefix(videoURL, "/static/videos/") {
+		// Local static file
+		srcPath, _ = s.staticFilePath(videoURL)
+	} else if strings.HasPrefix(videoURL, "http") {
+		// Download from remote URL
+		resp, err := s.httpGet(ctx, videoURL)
+		if err != nil {
+			return fmt.Errorf("failed to download video: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if err := saveDownload(resp, destPath, "video", s.MaxVideoDownload); err != nil {
+			return fmt.Errorf("failed to download video: %w", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("unsupported video URL format")
+	}
+
+	// Copy local file
+	input, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to read source video: %w", err)
+	}
+	defer input.Close()
+
+	if _, err := writeFileAtomic(destPath, input, 0644); err != nil {
+		return fmt.Errorf("failed to write video file: %w", err)
+	}
+
+	return nil
+}
+
+// saveDownload writes a fetched image or video (kind) to destPath, refusing
+// bodies over limit bytes and Content-Types other than kind/*. Other types are
+// let through when the bytes sniff as kind, since some hosts label everything
+// application/octet-stream.
+// The file is written atomically, so a failed download leaves nothing behind.
+func saveDownload(resp *http.Response, destPath, kind string, limit int64) error {
+	if limit > 0 && resp.ContentLength > limit {
+		return fmt.Errorf("%w: %s is %d MB, over the %d MB %s limit", errDownloadTooLarge,
+			redactURL(resp.Request.URL.String()), resp.ContentLength>>20, limit>>20, kind)
+	}
+
+	body := bufio.NewReaderSize(resp.Body, 512)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, kind+"/") {
+		head, _ := body.Peek(512)
+		if !sniffsAs(head, kind) {
+			if mediaType == "" {
+				mediaType = "unknown type"
+			}
+			return fmt.Errorf("%s is %s and doesn't contain %s data", redactURL(resp.Request.URL.String()), mediaType, kind)
+		}
+	}
+
+	var r io.Reader = body
+	if limit > 0 {
+		r = http.MaxBytesReader(nil, io.NopCloser(body), limit)
+	}
+	if _, err := writeFileAtomic(destPath, r, 0644); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fmt.Errorf("%w: %s is over the %d MB %s limit", errDownloadTooLarge,
+				redactURL(resp.Request.URL.String()), limit>>20, kind)
+		}
+		return err
+	}
+	return nil
+}
+
+// sniffsAs reports whether a file's first bytes look like an image or video.
+func sniffsAs(head []byte, kind string) bool {
+	if kind == "video" {
+		_, ok := sniffVideoExtension(head)
+		return ok
+	}
+	return strings.HasPrefix(http.DetectContentType(head), kind+"/")
+}
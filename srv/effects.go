@@ -0,0 +1,135 @@
+package srv
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Effect is the Ken Burns move applied to a single-image clip. A Preset
+// supplies defaults; any other field set alongside it overrides the preset.
+type Effect struct {
+	Preset string `json:"preset,omitempty"`
+	// Zoom is "in", "out" or "none"
+	Zoom string `json:"zoom,omitempty"`
+	// Pan is "left", "right", "up", "down", or empty to stay centered
+	Pan string `json:"pan,omitempty"`
+	// Intensity is the extra zoom the move reaches (0.3 ends at 1.3x);
+	// zooms get there over ten seconds, pans hold it throughout
+	Intensity float64 `json:"intensity,omitempty"`
+}
+
+// defaultEffectPreset is the original slow centered zoom.
+const defaultEffectPreset = "slow-zoom-in"
+
+// effectPresets are the named moves selectable by Effect.Preset.
+var effectPresets = map[string]Effect{
+	"slow-zoom-in":  {Zoom: "in", Intensity: 0.3},
+	"zoom-in":       {Zoom: "in", Intensity: 0.6},
+	"slow-zoom-out": {Zoom: "out", Intensity: 0.3},
+	"zoom-out":      {Zoom: "out", Intensity: 0.6},
+	"pan-left":      {Zoom: "none", Pan: "left", Intensity: 0.2},
+	"pan-right":     {Zoom: "none", Pan: "right", Intensity: 0.2},
+	"pan-up":        {Zoom: "none", Pan: "up", Intensity: 0.2},
+	"pan-down":      {Zoom: "none", Pan: "down", Intensity: 0.2},
+	"static":        {Zoom: "none"},
+}
+
+// zoomRateFrames is how many frames a zoom takes to reach its full
+// intensity: ten seconds at 30fps.
+const zoomRateFrames = 300
+
+// resolve applies the preset (the default one if none is named) under any
+// explicitly set fields, and validates the result.
+func (e Effect) resolve() (Effect, error) {
+	name := e.Preset
+	if name == "" {
+		name = defaultEffectPreset
+	}
+	resolved, ok := effectPresets[name]
+	if !ok {
+		return Effect{}, fmt.Errorf("unknown effect preset %q (supported: %s)", name, strings.Join(slices.Sorted(maps.Keys(effectPresets)), ", "))
+	}
+	resolved.Preset = name
+	if e.Zoom != "" {
+		resolved.Zoom = e.Zoom
+	}
+	if e.Pan != "" {
+		resolved.Pan = e.Pan
+	}
+	if e.Intensity != 0 {
+		resolved.Intensity = e.Intensity
+	}
+
+	switch {
+	case !slices.Contains([]string{"in", "out", "none"}, resolved.Zoom):
+		return Effect{}, fmt.Errorf("effect zoom must be in, out or none, got %q", resolved.Zoom)
+	case !slices.Contains([]string{"", "left", "right", "up", "down"}, resolved.Pan):
+		return Effect{}, fmt.Errorf("effect pan must be left, right, up or down, got %q", resolved.Pan)
+	case resolved.Intensity < 0 || resolved.Intensity > 1:
+		return Effect{}, fmt.Errorf("effect intensity must be between 0 and 1, got %g", resolved.Intensity)
+	}
+	return resolved, nil
+}
+
+// zoompan returns the z, x and y options of a zoompan filter for a resolved
+// effect over a clip of frames frames. Pans sweep the whole slack between
+// the zoomed view and the frame edge over the clip.
+func (e Effect) zoompan(frames int) string {
+	maxZoom := 1 + e.Intensity
+	step := e.Intensity / zoomRateFrames
+
+	var z string
+	switch e.Zoom {
+	case "in":
+		z = fmt.Sprintf("min(zoom+%.6g,%.6g)", step, maxZoom)
+	case "out":
+		z = fmt.Sprintf("if(lte(zoom,1.0),%.6g,max(1.001,zoom-%.6g))", maxZoom, step)
+	default:
+		z = fmt.Sprintf("%.6g", maxZoom)
+	}
+
+	x, y := "iw/2-(iw/zoom/2)", "ih/2-(ih/zoom/2)"
+	progress := fmt.Sprintf("on/%d", max(1, frames-1))
+	switch e.Pan {
+	case "right":
+		x = "(iw-iw/zoom)*" + progress
+	case "left":
+		x = "(iw-iw/zoom)*(1-" + progress + ")"
+	case "down":
+		y = "(ih-ih/zoom)*" + progress
+	case "up":
+		y = "(ih-ih/zoom)*(1-" + progress + ")"
+	}
+	return fmt.Sprintf("z='%s':x='%s':y='%s'", z, x, y)
+}
+
+// defaultTransition is the xfade transition between a clip's two frames.
+const defaultTransition = "fade"
+
+// xfadeTransitions are the xfade transition names a request may pick.
+var xfadeTransitions = []string{
+	"fade", "fadeblack", "fadewhite", "fadegrays", "dissolve", "distance",
+	"wipeleft", "wiperight", "wipeup", "wipedown",
+	"wipetl", "wipetr", "wipebl", "wipebr",
+	"slideleft", "slideright", "slideup", "slidedown",
+	"smoothleft", "smoothright", "smoothup", "smoothdown",
+	"circlecrop", "rectcrop", "circleopen", "circleclose",
+	"vertopen", "vertclose", "horzopen", "horzclose",
+	"diagtl", "diagtr", "diagbl", "diagbr",
+	"hlslice", "hrslice", "vuslice", "vdslice",
+	"radial", "pixelize", "hblur", "squeezeh", "squeezev", "zoomin",
+}
+
+// validateTransition checks an xfade transition name and duration (seconds)
+// for a clip of the given length. Empty and zero mean the defaults.
+func validateTransition(name string, fade float64, duration int) error {
+	if name != "" && !slices.Contains(xfadeTransitions, name) {
+		return fmt.Errorf("unknown transition %q (supported: %s)", name, strings.Join(xfadeTransitions, ", "))
+	}
+	if fade < 0 || fade > float64(duration) {
+		return fmt.Errorf("transitionDuration must be between 0 and the clip duration (%ds), got %g", duration, fade)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package srv
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Encoding picks the codec, quality and container of a delivery clip. The
+// zero value is the original libx264 encode at ffmpeg's defaults, in mp4.
+type Encoding struct {
+	// Codec is "h264", "h265" or "vp9"
+	Codec string `json:"codec,omitempty"`
+	// CRF is the constant rate factor: lower is better quality and bigger
+	// files. Unset leaves the encoder's default.
+	CRF *int `json:"crf,omitempty"`
+	// Preset trades encode speed for size: an x264/x265 preset ("slow"), or
+	// a vp9 deadline ("good")
+	Preset string `json:"preset,omitempty"`
+	// Container is "mp4" or "webm"; it follows from the codec when unset
+	Container string `json:"container,omitempty"`
+}
+
+// videoCodec is how one Encoding.Codec maps onto ffmpeg.
+type videoCodec struct {
+	encoder    string
+	maxCRF     int
+	presetFlag string
+	presets    []string
+	containers []string
+	// extra are flags the encoder always needs, e.g. so vp9 honors the CRF
+	extra []string
+}
+
+// x26xPresets are the presets libx264 and libx265 share.
+var x26xPresets = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow"}
+
+// defaultCodec is the codec clips were always encoded with.
+const defaultCodec = "h264"
+
+// videoCodecs are the codecs Encoding.Codec may name.
+var videoCodecs = map[string]videoCodec{
+	"h264": {encoder: "libx264", maxCRF: 51, presetFlag: "-preset", presets: x26xPresets, containers: []string{"mp4"}},
+	// hvc1 is the tag Apple players require to play HEVC in mp4
+	"h265": {encoder: "libx265", maxCRF: 51, presetFlag: "-preset", presets: x26xPresets, containers: []string{"mp4"}, extra: []string{"-tag:v", "hvc1"}},
+	// -b:v 0 makes libvpx-vp9 encode at constant quality
+	"vp9": {encoder: "libvpx-vp9", maxCRF: 63, presetFlag: "-deadline", presets: []string{"good", "best", "realtime"}, containers: []string{"webm"}, extra: []string{"-b:v", "0"}},
+}
+
+// resolve fills in the default codec and container and validates the
+// combination.
+func (e Encoding) resolve() (Encoding, error) {
+	if e.Codec == "" {
+		e.Codec = defaultCodec
+	}
+	codec, ok := videoCodecs[e.Codec]
+	if !ok {
+		return Encoding{}, fmt.Errorf("unknown codec %q (supported: %s)", e.Codec, strings.Join(slices.Sorted(maps.Keys(videoCodecs)), ", "))
+	}
+	if e.Container == "" {
+		e.Container = codec.containers[0]
+	}
+
+	switch {
+	case !slices.Contains(codec.containers, e.Container):
+		return Encoding{}, fmt.Errorf("codec %s can't be stored in %s (use %s)", e.Codec, e.Container, strings.Join(codec.containers, " or "))
+	case e.CRF != nil && (*e.CRF < 0 || *e.CRF > codec.maxCRF):
+		return Encoding{}, fmt.Errorf("crf for %s must be between 0 and %d, got %d", e.Codec, codec.maxCRF, *e.CRF)
+	case e.Preset != "" && !slices.Contains(codec.presets, e.Preset):
+		return Encoding{}, fmt.Errorf("unknown %s preset %q (supported: %s)", e.Codec, e.Preset, strings.Join(codec.presets, ", "))
+	}
+	return e, nil
+}
+
+// isDefault reports whether e asks for nothing beyond the original encode.
+func (e Encoding) isDefault() bool {
+	return (e.Codec == "" || e.Codec == defaultCodec) && e.CRF == nil && e.Preset == "" && (e.Container == "" || e.Container == "mp4")
+}
+
+// args returns the ffmpeg video encoding flags for a resolved encoding.
+func (e Encoding) args() []string {
+	codec := videoCodecs[e.Codec]
+	if codec.encoder == "" {
+		codec = videoCodecs[defaultCodec]
+	}
+	args := []string{"-c:v", codec.encoder}
+	if e.CRF != nil {
+		args = append(args, "-crf", strconv.Itoa(*e.CRF))
+	}
+	if e.Preset != "" {
+		args = append(args, codec.presetFlag, e.Preset)
+	}
+	args = append(args, codec.extra...)
+	return append(args, "-pix_fmt", "yuv420p")
+}
+
+// extension is the clip file extension for a resolved encoding.
+func (e Encoding) extension() string {
+	if e.Container == "webm" {
+		return ".webm"
+	}
+	return ".mp4"
+}
+
+// audioArgs encode the narration track in a codec the container allows.
+// The narration is padded with endless silence, so -shortest ends it with
+// the video rather than letting a short narration cut the clip.
+func (e Encoding) audioArgs() []string {
+	if e.Container == "webm" {
+		return []string{"-c:a", "libopus", "-shortest"}
+	}
+	return []string{"-c:a", "aac", "-shortest"}
+}
@@ -0,0 +1,250 @@
+package srv
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var sceneClipPattern = regexp.MustCompile(`^scene_(\d+)\.(mp4|webm|mov)$`)
+
+type sceneClip struct {
+	index int
+	path  string
+	ext   string
+}
+
+// listSceneClips returns the per-scene videos in a project's videos dir,
+// ordered by scene index (so scene_10 sorts after scene_2).
+func listSceneClips(projectPath string) ([]sceneClip, error) {
+	videosDir := filepath.Join(projectPath, "videos")
+	entries, err := os.ReadDir(videosDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var clips []sceneClip
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := sceneClipPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[1])
+		clips = append(clips, sceneClip{
+			index: index,
+			path:  filepath.Join(videosDir, entry.Name()),
+			ext:   match[2],
+		})
+	}
+	sort.Slice(clips, func(i, j int) bool { return clips[i].index < clips[j].index })
+	return clips, nil
+}
+
+// HandleExportClips streams a ZIP of just the per-scene video files, named
+// scene_01.mp4, scene_02.mp4, ... so they sort correctly in a file manager.
+func (s *Server) HandleExportClips(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	projectPath, err := s.projectDir(projectID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	clips, err := listSceneClips(projectPath)
+	if err != nil && !os.IsNotExist(err) {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read videos directory: "+err.Error())
+		return
+	}
+	if len(clips) == 0 {
+		writeJSONError(w, http.StatusNotFound, "No scene clips found")
+		return
+	}
+
+	width := len(strconv.Itoa(clips[len(clips)-1].index))
+	if width < 2 {
+		width = 2
+	}
+
+	entries := make([]zipEntry, 0, len(clips))
+	for _, clip := range clips {
+		info, err := os.Stat(clip.path)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to read clip: "+err.Error())
+			return
+		}
+		entries = append(entries, zipEntry{
+			path:     clip.path,
+			name:     fmt.Sprintf("scene_%0*d.%s", width, clip.index, clip.ext),
+			size:     info.Size(),
+			modified: info.ModTime(),
+		})
+	}
+
+	// Stored entries make the archive size computable up front, so the
+	// browser can show real download progress
+	size, err := storedZipSize(entries)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to size archive: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", projectID+"-clips.zip"))
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+
+	err = writeStoredZip(w, entries, func(e zipEntry) (io.ReadCloser, error) {
+		return os.Open(e.path)
+	})
+	if err != nil {
+		// Headers are already sent; all we can do is log and truncate
+		slog.Error("export clips", "project", projectID, "error", err)
+	}
+}
+
+// HandleExportProject streams the whole project folder (project.json,
+// images, keyframes, videos, final.mp4) as a ZIP under a top-level folder
+// named for the project, leaving out hidden and partially written files.
+func (s *Server) HandleExportProject(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	projectPath, err := s.projectDir(projectID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	entries, err := projectExportEntries(projectPath, projectID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read project: "+err.Error())
+		return
+	}
+	size, err := storedZipSize(entries)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to size archive: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", projectID+".zip"))
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+
+	err = writeStoredZip(w, entries, func(e zipEntry) (io.ReadCloser, error) {
+		return os.Open(e.path)
+	})
+	if err != nil {
+		// Headers are already sent; all we can do is log and truncate
+		slog.Error("export project", "project", projectID, "error", err)
+	}
+}
+
+// projectExportEntries lists the regular files under projectPath, named
+// prefix/relative/path. Hidden files and folders are skipped, which covers
+// the in-progress renders and atomic-write temp files, as are .tmp and
+// .partial leftovers.
+func projectExportEntries(projectPath, prefix string) ([]zipEntry, error) {
+	var entries []zipEntry
+	err := filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == projectPath {
+			return nil
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, ".partial") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(projectPath, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, zipEntry{
+			path:     path,
+			name:     prefix + "/" + filepath.ToSlash(rel),
+			size:     info.Size(),
+			modified: info.ModTime(),
+		})
+		return nil
+	})
+	return entries, err
+}
+
+// zipEntry is a file to add to an archive under a new name.
+type zipEntry struct {
+	path     string
+	name     string
+	size     int64
+	modified time.Time
+}
+
+// writeStoredZip writes entries into a ZIP archive. Entries are stored rather
+// than deflated since video and image data is already compressed, which also
+// keeps the archive size independent of the file contents.
+func writeStoredZip(w io.Writer, entries []zipEntry, open func(zipEntry) (io.ReadCloser, error)) error {
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Store, Modified: e.modified}
+		header.SetMode(0644)
+		dst, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		src, err := open(e)
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(dst, src, e.size)
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.path, err)
+		}
+	}
+	return zw.Close()
+}
+
+// storedZipSize returns the exact byte size writeStoredZip will produce, by
+// writing the archive with zeroed contents to a counter.
+func storedZipSize(entries []zipEntry) (int64, error) {
+	var counter countingWriter
+	err := writeStoredZip(&counter, entries, func(zipEntry) (io.ReadCloser, error) {
+		return io.NopCloser(zeroReader{}), nil
+	})
+	return int64(counter), err
+}
+
+type countingWriter int64
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
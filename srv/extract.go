@@ -0,0 +1,139 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// defaultSceneThreshold is the scene-change score (0-1) above which a frame
+// counts as a cut. Lower values pick up more, subtler cuts.
+const defaultSceneThreshold = 0.4
+
+var showinfoPTSTime = regexp.MustCompile(`pts_time:\s*([0-9.]+)`)
+
+// parseSceneTimes pulls the timestamp of each selected frame from ffmpeg's
+// showinfo output, in output order.
+func parseSceneTimes(output string) []float64 {
+	var times []float64
+	for _, match := range showinfoPTSTime.FindAllStringSubmatch(output, -1) {
+		if t, err := strconv.ParseFloat(match[1], 64); err == nil {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+// KeyframeCandidate is a frame extracted at a scene cut in reference footage.
+type KeyframeCandidate struct {
+	ImageURL string  `json:"imageUrl"`
+	Time     float64 `json:"time"`
+}
+
+// HandleExtractKeyframes takes an uploaded video and saves a frame from the
+// start of each detected scene as a keyframe candidate. The optional
+// "threshold" form field tunes scene-cut sensitivity.
+func (s *Server) HandleExtractKeyframes(w http.ResponseWriter, r *http.Request) {
+	if !parseUpload(w, r, s.MaxUploadSize) {
+		return
+	}
+
+	threshold := defaultSceneThreshold
+	if v := r.FormValue("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t <= 0 || t >= 1 {
+			writeJSONError(w, http.StatusBadRequest, "threshold must be a number between 0 and 1")
+			return
+		}
+		threshold = t
+	}
+
+	file, header, err := r.FormFile("video")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to get video file: "+err.Error())
+		return
+	}
+	defer file.Close()
+
+	tmpDir, err := os.MkdirTemp("", tempDirPrefix+"-extract-")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create temp directory: "+err.Error())
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	srcPath := filepath.Join(tmpDir, "source"+videoExtension(header.Filename))
+	src, err := os.Create(srcPath)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save video: "+err.Error())
+		return
+	}
+	_, err = io.Copy(src, file)
+	src.Close()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save video: "+err.Error())
+		return
+	}
+
+	extractID := randomID("extract_")
+	outDir := filepath.Join(s.StaticDir, "keyframes", extractID)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create keyframes directory: "+err.Error())
+		return
+	}
+
+	// Always keep the first frame so the opening scene has a keyframe too
+	filter := fmt.Sprintf("select='eq(n,0)+gt(scene,%g)',showinfo", threshold)
+	output, err := s.runFFmpeg(r.Context(), []string{"-y",
+		"-i", srcPath,
+		"-vf", filter,
+		"-vsync", "vfr",
+		filepath.Join(outDir, "candidate_%03d.png"),
+	})
+	if err != nil {
+		os.RemoveAll(outDir)
+		if errors.Is(err, context.DeadlineExceeded) {
+			writeJSONError(w, http.StatusGatewayTimeout, "Keyframe extraction timed out")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to extract keyframes: "+err.Error())
+		return
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read keyframes: "+err.Error())
+		return
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	times := parseSceneTimes(output)
+	candidates := make([]KeyframeCandidate, len(names))
+	for i, name := range names {
+		candidates[i].ImageURL = fmt.Sprintf("/static/keyframes/%s/%s", extractID, name)
+		if i < len(times) {
+			candidates[i].Time = times[i]
+		}
+	}
+
+	slog.Info("extracted keyframes", "source", header.Filename, "threshold", threshold, "count", len(candidates))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"keyframes": candidates,
+		"threshold": threshold,
+	})
+}
@@ -0,0 +1,278 @@
+package srv
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultFFmpegRetries is how many times a failed FFmpeg run is retried when
+// the failure doesn't look deterministic.
+const defaultFFmpegRetries = 2
+
+// defaultFFmpegTimeout bounds a single FFmpeg run so a hung process can't
+// hold its slot forever.
+const defaultFFmpegTimeout = 5 * time.Minute
+
+// ffprobeTimeout bounds a single ffprobe run; reading a header should take
+// well under a second, so one that takes this long is hung.
+const ffprobeTimeout = 30 * time.Second
+
+// maxFFmpegOutput is how much of FFmpeg's log is kept; only the tail, where
+// the errors are, is useful.
+const maxFFmpegOutput = 64 << 10
+
+// ffmpegPermanentErrors are stderr fragments for failures that will recur on
+// every attempt (bad filters, options or inputs), so retrying only wastes time.
+var ffmpegPermanentErrors = []string{
+	"No such filter",
+	"Error parsing",
+	"Error initializing filter",
+	"Invalid argument",
+	"Option not found",
+	"Unrecognized option",
+	"Unknown encoder",
+	"Invalid data found when processing input",
+	"No such file or directory",
+}
+
+// isTransientFFmpegError reports whether an FFmpeg failure is worth retrying,
+// e.g. an output file momentarily locked by another process.
+func isTransientFFmpegError(err error, output string) bool {
+	if errors.Is(err, exec.ErrNotFound) {
+		return false
+	}
+	for _, msg := range ffmpegPermanentErrors {
+		if strings.Contains(output, msg) {
+			return false
+		}
+	}
+	return true
+}
+
+// ffmpegWorkers is how many ffmpeg processes may run at once: half the CPUs,
+// since each encode is itself multi-threaded.
+func ffmpegWorkers() int {
+	return max(1, runtime.NumCPU()/2)
+}
+
+// runFFmpeg runs ffmpeg with args, retrying up to s.FFmpegRetries times on
+// transient failures. It returns the combined output of the last attempt.
+// Each attempt waits for one of the server's ffmpeg slots and is killed
+// after s.FFmpegTimeout (or ctx's own deadline); a timeout is not retried and
+// its error wraps context.DeadlineExceeded.
+func (s *Server) runFFmpeg(ctx context.Context, args []string) (string, error) {
+	return s.runFFmpegProgress(ctx, args, nil)
+}
+
+// runFFmpegProgress is runFFmpeg that also reports encoding progress. When
+// onProgress is set, ffmpeg writes -progress reports to stdout and the
+// returned output is stderr only. A retry starts reporting from zero again.
+func (s *Server) runFFmpegProgress(ctx context.Context, args []string, onProgress func(ffmpegProgress)) (string, error) {
+	if onProgress != nil {
+		args = append([]string{"-progress", "pipe:1", "-nostats"}, args...)
+	}
+
+	var err error
+	var output []byte
+	for attempt := 0; attempt <= s.FFmpegRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		}
+		s.ffmpegSlots <- struct{}{}
+		attemptCtx, cancel := context.WithTimeout(ctx, s.FFmpegTimeout)
+		output, err = execFFmpeg(attemptCtx, args, onProgress)
+		ctxErr := attemptCtx.Err()
+		cancel()
+		<-s.ffmpegSlots
+		if err == nil {
+			return string(output), nil
+		}
+		if ctxErr != nil {
+			slog.Error("ffmpeg stopped", "error", ctxErr, "timeout", s.FFmpegTimeout, "output", string(output))
+			if errors.Is(ctxErr, context.DeadlineExceeded) {
+				return string(output), fmt.Errorf("ffmpeg timed out after %s: %w", s.FFmpegTimeout, ctxErr)
+			}
+			return string(output), fmt.Errorf("ffmpeg cancelled: %w", ctxErr)
+		}
+		transient := isTransientFFmpegError(err, string(output))
+		slog.Warn("ffmpeg attempt failed", "attempt", attempt+1, "transient", transient, "error", err)
+		if !transient {
+			break
+		}
+	}
+	slog.Error("ffmpeg failed", "error", err, "output", string(output))
+	return string(output), fmt.Errorf("ffmpeg error: %v - %s", err, string(output))
+}
+
+// runFFprobe runs ffprobe with args and returns its stdout. It is killed
+// after ffprobeTimeout or when ctx ends, so a hung probe can't stall the job
+// or request waiting on it.
+func runFFprobe(ctx context.Context, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, ffprobeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "ffprobe", args...)
+	killProcessGroupOnCancel(cmd)
+	cmd.WaitDelay = time.Second
+	output, err := cmd.Output()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("ffprobe timed out after %s: %w", ffprobeTimeout, ctxErr)
+		}
+		return nil, fmt.Errorf("ffprobe cancelled: %w", ctxErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe: %w", err)
+	}
+	return output, nil
+}
+
+// execFFmpeg runs one ffmpeg process, keeping only the tail of its log. When
+// ctx ends the whole process group is killed, so helpers ffmpeg spawned
+// don't keep it alive.
+func execFFmpeg(ctx context.Context, args []string, onProgress func(ffmpegProgress)) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	killProcessGroupOnCancel(cmd)
+	// Don't wait forever on pipes held open by an orphaned child
+	cmd.WaitDelay = 5 * time.Second
+
+	output := &tailBuffer{max: maxFFmpegOutput}
+	cmd.Stderr = output
+	if onProgress == nil {
+		cmd.Stdout = output
+		err := cmd.Run()
+		return output.Bytes(), err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	parseFFmpegProgress(stdout, onProgress)
+	err = cmd.Wait()
+	return output.Bytes(), err
+}
+
+// tailBuffer is a writer that keeps only the last max bytes written.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) > t.max {
+		p = p[len(p)-t.max:]
+	}
+	if overflow := len(t.buf) + len(p) - t.max; overflow > 0 {
+		t.buf = slices.Delete(t.buf, 0, overflow)
+	}
+	t.buf = append(t.buf, p...)
+	return n, nil
+}
+
+func (t *tailBuffer) Bytes() []byte {
+	return t.buf
+}
+
+// ffmpegStatus is what checkFFmpeg found when the server started.
+type ffmpegStatus struct {
+	Available bool   `json:"available"`
+	Version   string `json:"version,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// checkFFmpeg runs ffmpeg -version to find out whether ffmpeg is installed,
+// so a missing binary is reported up front rather than mid-render.
+func checkFFmpeg() ffmpegStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-version")
+	killProcessGroupOnCancel(cmd)
+	cmd.WaitDelay = time.Second
+	output, err := cmd.Output()
+	if err != nil {
+		return ffmpegStatus{Error: err.Error()}
+	}
+	// "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 ..."
+	first, _, _ := strings.Cut(string(output), "\n")
+	version := strings.TrimPrefix(first, "ffmpeg version ")
+	version, _, _ = strings.Cut(version, " ")
+	return ffmpegStatus{Available: true, Version: version}
+}
+
+// ffmpegProgress is one report from ffmpeg's -progress output.
+type ffmpegProgress struct {
+	Frame   int
+	OutTime time.Duration
+	Done    bool
+}
+
+// parseFFmpegProgress reads -progress key=value lines and calls onProgress
+// at the end of each report block.
+func parseFFmpegProgress(r io.Reader, onProgress func(ffmpegProgress)) {
+	var p ffmpegProgress
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		switch key {
+		case "frame":
+			p.Frame, _ = strconv.Atoi(value)
+		case "out_time_ms":
+			// Microseconds, despite the name; "N/A" before the first frame
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil {
+				p.OutTime = time.Duration(us) * time.Microsecond
+			}
+		case "progress":
+			p.Done = value == "end"
+			onProgress(p)
+		}
+	}
+	// Drain so ffmpeg never blocks on a full pipe
+	io.Copy(io.Discard, r)
+}
+
+// customFilterPattern is the character set allowed in user filter steps: filter
+// names, option=value pairs, numbers and simple expressions. It excludes
+// quotes, brackets and ';' so a step can't escape the chain, add labels or
+// start a new filtergraph.
+var customFilterPattern = regexp.MustCompile(`^[A-Za-z0-9_=:,.+\-*/() ]+$`)
+
+// blockedFilters read files or accept runtime commands and must never be
+// reachable from user input.
+var blockedFilters = []string{"movie", "amovie", "sendcmd", "asendcmd", "zmq", "azmq", "lut3d", "haldclutsrc", "subtitles", "ass", "drawtext"}
+
+// maxCustomFilterLen bounds the user-supplied filter string.
+const maxCustomFilterLen = 512
+
+// validateExtraFilters checks user-supplied filter steps before they are
+// appended to a filtergraph. FFmpeg is run without a shell, so this guards
+// the filtergraph syntax itself.
+func validateExtraFilters(filters string) error {
+	if len(filters) > maxCustomFilterLen {
+		return fmt.Errorf("extraFilters must be at most %d characters", maxCustomFilterLen)
+	}
+	if !customFilterPattern.MatchString(filters) {
+		return errors.New("extraFilters may only contain letters, digits, spaces and _=:,.+-*/()")
+	}
+	for _, step := range strings.Split(filters, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(step), "=")
+		if slices.Contains(blockedFilters, strings.ToLower(name)) {
+			return fmt.Errorf("filter %q is not allowed in extraFilters", name)
+		}
+	}
+	return nil
+}
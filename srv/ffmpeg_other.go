@@ -0,0 +1,9 @@
+//go:build !unix
+
+package srv
+
+import "os/exec"
+
+// killProcessGroupOnCancel is a no-op without Unix process groups; the
+// default cancellation kills just ffmpeg itself.
+func killProcessGroupOnCancel(cmd *exec.Cmd) {}
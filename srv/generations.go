@@ -0,0 +1,140 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// Kinds of recorded generation results.
+const (
+	generatedCharacter  = "character"
+	generatedSceneImage = "scene-image"
+	generatedSceneVideo = "scene-video"
+)
+
+// recordGeneration persists a generated media URL as soon as the provider
+// returns it, keyed by project (ID or folder path) and item, so the paid work
+// survives a crash or the client navigating away before it saves. It uses a
+// background context on purpose: the client disconnecting is exactly the case
+// this protects against.
+func (s *Server) recordGeneration(projectKey, kind string, index int, url, provider string) {
+	if projectKey == "" || url == "" {
+		return
+	}
+	err := dbgen.New(s.DB).UpsertGenerationResult(context.Background(), dbgen.UpsertGenerationResultParams{
+		ProjectKey: projectKey,
+		Kind:       kind,
+		ItemIndex:  int64(index),
+		Url:        url,
+		Provider:   provider,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		slog.Warn("record generation", "project", projectKey, "kind", kind, "index", index, "error", err)
+	}
+}
+
+// reconcileGenerations fills scenes and character art the client didn't send
+// with recorded results for any of the project keys, so a save after a crash
+// keeps them without re-generating. Scene results are keyed by zero-based
+// position; character results by character index.
+func (s *Server) reconcileGenerations(ctx context.Context, keys []string, scenes, artImages []map[string]any) ([]map[string]any, int) {
+	recovered := 0
+	q := dbgen.New(s.DB)
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		results, err := q.ListGenerationResults(ctx, key)
+		if err != nil {
+			slog.Warn("list generation results", "project", key, "error", err)
+			continue
+		}
+		for _, result := range results {
+			i := int(result.ItemIndex)
+			switch result.Kind {
+			case generatedSceneImage, generatedSceneVideo:
+				field := "imageUrl"
+				if result.Kind == generatedSceneVideo {
+					field = "videoUrl"
+				}
+				if i < 0 || i >= len(scenes) {
+					continue
+				}
+				if v, _ := scenes[i][field].(string); v == "" {
+					scenes[i][field] = result.Url
+					recovered++
+				}
+			case generatedCharacter:
+				found := false
+				for _, art := range artImages {
+					if idx, ok := art["index"].(float64); ok && int(idx) == i {
+						found = true
+						if v, _ := art["imageUrl"].(string); v == "" {
+							art["imageUrl"] = result.Url
+							recovered++
+						}
+					}
+				}
+				if !found {
+					artImages = append(artImages, map[string]any{"index": float64(i), "imageUrl": result.Url})
+					recovered++
+				}
+			}
+		}
+	}
+	return artImages, recovered
+}
+
+// clearGenerations drops recorded results once a save has persisted them.
+func (s *Server) clearGenerations(ctx context.Context, keys []string) {
+	q := dbgen.New(s.DB)
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if err := q.DeleteGenerationResults(ctx, key); err != nil {
+			slog.Warn("clear generation results", "project", key, "error", err)
+		}
+	}
+}
+
+// GenerationResult is a recorded generation that hasn't been saved yet.
+type GenerationResult struct {
+	Kind      string    `json:"kind"`
+	Index     int       `json:"index"`
+	URL       string    `json:"url"`
+	Provider  string    `json:"provider,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// HandleListGenerations returns the generation results recorded for a project
+// that haven't been saved yet, so a reloaded client can recover them.
+func (s *Server) HandleListGenerations(w http.ResponseWriter, r *http.Request) {
+	rows, err := dbgen.New(s.DB).ListGenerationResults(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to list generations: "+err.Error())
+		return
+	}
+
+	results := make([]GenerationResult, len(rows))
+	for i, row := range rows {
+		results[i] = GenerationResult{
+			Kind:      row.Kind,
+			Index:     int(row.ItemIndex),
+			URL:       row.Url,
+			Provider:  row.Provider,
+			CreatedAt: row.CreatedAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"generations": results,
+	})
+}
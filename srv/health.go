@@ -0,0 +1,87 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// minFreeDisk is the free space under ProjectsRoot below which renders are
+// likely to fail partway, so the instance reports itself unhealthy.
+const minFreeDisk = 1 << 30
+
+// renderJobKinds are the job kinds counted as in-flight renders.
+var renderJobKinds = []string{"generate-video", "render-final", "video-clips"}
+
+// healthCheck is the state of one dependency in a health report.
+type healthCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// diskHealth is the free space check under ProjectsRoot.
+type diskHealth struct {
+	healthCheck
+	Path      string `json:"path"`
+	FreeBytes uint64 `json:"freeBytes"`
+}
+
+// HandleHealth reports the database, ffmpeg and free disk space under
+// ProjectsRoot, plus the number of in-flight render jobs. It returns 503 when
+// any of them is down so a load balancer can take the instance out of
+// rotation.
+func (s *Server) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	database := healthCheck{OK: true}
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := s.DB.PingContext(ctx); err != nil {
+		database = healthCheck{Error: err.Error()}
+	}
+
+	disk := diskHealth{Path: existingAncestor(s.ProjectsRoot)}
+	free, err := diskFree(disk.Path)
+	switch {
+	case err != nil:
+		disk.Error = err.Error()
+	case free < minFreeDisk:
+		disk.FreeBytes = free
+		disk.Error = "less than 1GB free"
+	default:
+		disk.FreeBytes = free
+		disk.OK = true
+	}
+
+	status, code := "ok", http.StatusOK
+	if !database.OK || !s.ffmpeg.Available || !disk.OK {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":     status,
+		"database":   database,
+		"ffmpeg":     s.ffmpeg,
+		"disk":       disk,
+		"renderJobs": s.activeJobs(renderJobKinds...),
+	})
+}
+
+// existingAncestor returns path, or its nearest parent that exists, so free
+// space can be reported before ProjectsRoot is first created.
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,391 @@
+package srv
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// JobStatus is the lifecycle state of a background job or one of its items.
+type JobStatus string
+
+const (
+	JobQueued  JobStatus = "queued"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// Job tracks a long-running operation so handlers can return immediately and
+// let the client poll GET /api/jobs/{id} for progress.
+type Job struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	ProjectID string    `json:"projectId,omitempty"`
+	Status    JobStatus `json:"status"`
+	Completed int       `json:"completed"`
+	Total     int       `json:"total"`
+	Items     []JobItem `json:"items,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// changed is closed and replaced on every update; see watchJob
+	changed chan struct{}
+}
+
+// JobItem reports progress for a single unit of work within a job (e.g. one scene).
+type JobItem struct {
+	Kind   string    `json:"kind,omitempty"`
+	Index  int       `json:"index"`
+	Status JobStatus `json:"status"`
+	// Progress is the percent complete, for items that report it
+	Progress float64 `json:"progress,omitempty"`
+	Result   string  `json:"result,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	// TimedOut marks a failure caused by a deadline (e.g. a hung ffmpeg)
+	TimedOut bool `json:"timedOut,omitempty"`
+	// Video is a finished clip's metadata, probed from the file
+	Video *VideoMeta `json:"video,omitempty"`
+	// Clip is what a video-clips job was asked to make for this item; it's
+	// saved with the item so the clip status survives a restart
+	Clip *VideoClip `json:"clip,omitempty"`
+}
+
+// randomID returns a URL-safe random identifier with the given prefix.
+func randomID(prefix string) string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+// newJob registers a queued job with the given items.
+func (s *Server) newJob(kind, projectID string, items []JobItem) *Job {
+	now := time.Now()
+	job := &Job{
+		ID:        randomID("job_"),
+		Kind:      kind,
+		ProjectID: projectID,
+		Status:    JobQueued,
+		Total:     len(items),
+		Items:     make([]JobItem, len(items)),
+		CreatedAt: now,
+		UpdatedAt: now,
+		changed:   make(chan struct{}),
+	}
+	for i, item := range items {
+		item.Status = JobQueued
+		job.Items[i] = item
+	}
+
+	s.jobsMu.Lock()
+	s.jobs[job.ID] = job
+	s.jobsMu.Unlock()
+	s.saveJob(job.ID)
+	return job
+}
+
+// updateJob applies fn to the job under the jobs lock.
+func (s *Server) updateJob(id string, fn func(job *Job)) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	if job, ok := s.jobs[id]; ok {
+		fn(job)
+		job.UpdatedAt = time.Now()
+		close(job.changed)
+		job.changed = make(chan struct{})
+	}
+}
+
+// setJobItem records the outcome of one item and advances the completed count.
+func (s *Server) setJobItem(id string, item int, status JobStatus, result, errMsg string) {
+	s.updateJob(id, func(job *Job) {
+		job.Items[item].Status = status
+		job.Items[item].Result = result
+		job.Items[item].Error = errMsg
+		if status == JobDone {
+			job.Items[item].Progress = 100
+		}
+		if status == JobDone || status == JobFailed {
+			job.Completed++
+		}
+	})
+	s.jobStatusChanged(id)
+}
+
+// setJobProgress records an item's percent complete.
+func (s *Server) setJobProgress(id string, item int, percent float64) {
+	s.updateJob(id, func(job *Job) { job.Items[item].Progress = percent })
+}
+
+// recordClipMeta probes the finished clip at videoURL and records its
+// metadata on the job item. A clip that can't be probed is left without.
+func (s *Server) recordClipMeta(ctx context.Context, id string, item int, videoURL string) {
+	path, ok := s.staticFilePath(videoURL)
+	if !ok {
+		return
+	}
+	meta, err := probeVideo(ctx, path)
+	if err != nil {
+		slog.Warn("failed to probe clip", "url", videoURL, "error", err)
+		return
+	}
+	meta.Duration = math.Round(meta.Duration*100) / 100
+	s.updateJob(id, func(job *Job) { job.Items[item].Video = &meta })
+}
+
+// activeJobs counts the queued or running jobs of the given kinds.
+func (s *Server) activeJobs(kinds ...string) int {
+	s.jobsMu.RLock()
+	defer s.jobsMu.RUnlock()
+	n := 0
+	for _, job := range s.jobs {
+		if (job.Status == JobQueued || job.Status == JobRunning) && slices.Contains(kinds, job.Kind) {
+			n++
+		}
+	}
+	return n
+}
+
+// getJob returns a copy of the job that is safe to encode outside the lock.
+func (s *Server) getJob(id string) (Job, bool) {
+	s.jobsMu.RLock()
+	defer s.jobsMu.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	snapshot := *job
+	snapshot.Items = append([]JobItem(nil), job.Items...)
+	return snapshot, true
+}
+
+// watchJob returns a snapshot of the job and a channel that is closed on its
+// next update, so callers can wait for changes without polling.
+func (s *Server) watchJob(id string) (Job, <-chan struct{}, bool) {
+	s.jobsMu.RLock()
+	defer s.jobsMu.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, nil, false
+	}
+	snapshot := *job
+	snapshot.Items = append([]JobItem(nil), job.Items...)
+	return snapshot, job.changed, true
+}
+
+// jobTask performs one item of a job and returns its result, e.g. a new URL.
+type jobTask func() (string, error)
+
+// runJob runs tasks concurrently, each holding one of the provider's request
+// slots, and records per-item progress. tasks[i] reports to job.Items[i]. The
+// job only fails if every item failed. Local work that bounds itself (e.g.
+// ffmpeg) passes an empty provider to skip the slots.
+func (s *Server) runJob(jobID, provider string, tasks []jobTask) {
+	s.updateJob(jobID, func(job *Job) { job.Status = JobRunning })
+	s.jobStatusChanged(jobID)
+
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if provider != "" {
+				release := s.acquireProvider(provider)
+				defer release()
+			}
+
+			s.updateJob(jobID, func(job *Job) { job.Items[i].Status = JobRunning })
+			result, err := task()
+			if err != nil {
+				if errors.Is(err, context.DeadlineExceeded) {
+					s.updateJob(jobID, func(job *Job) { job.Items[i].TimedOut = true })
+				}
+				s.setJobItem(jobID, i, JobFailed, "", err.Error())
+				return
+			}
+			s.setJobItem(jobID, i, JobDone, result, "")
+		}()
+	}
+	wg.Wait()
+
+	var kind, projectID string
+	s.updateJob(jobID, func(job *Job) {
+		kind, projectID = job.Kind, job.ProjectID
+		job.Status = JobDone
+		failed := 0
+		for _, item := range job.Items {
+			if item.Status == JobFailed {
+				failed++
+			}
+		}
+		if failed > 0 {
+			job.Error = fmt.Sprintf("%d of %d items failed", failed, job.Total)
+		}
+		if failed > 0 && failed == job.Total {
+			job.Status = JobFailed
+		}
+	})
+	s.jobStatusChanged(jobID)
+	slog.Info("job finished", "job", jobID, "kind", kind, "project", projectID, "items", len(tasks))
+}
+
+func (s *Server) HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.getJob(r.PathValue("jobId"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// jobRetention is how long finished jobs are kept in the database and
+// restored after a restart.
+const jobRetention = 7 * 24 * time.Hour
+
+// errJobInterrupted is recorded on jobs that were running when the server
+// stopped; their workers are gone, so they can never finish.
+const errJobInterrupted = "the server restarted before this finished"
+
+// jobStatusChanged saves the job and tells its project's clients. Progress
+// updates don't call it; only status and item changes do.
+func (s *Server) jobStatusChanged(id string) {
+	s.saveJob(id)
+	s.broadcastJob(id)
+}
+
+// saveJob writes the job's current state to the database so its status
+// survives a restart. Saves are serialized and each takes its snapshot under
+// the lock, so an older snapshot never overwrites a newer one.
+func (s *Server) saveJob(id string) {
+	if s.DB == nil {
+		return
+	}
+	s.jobsDBMu.Lock()
+	defer s.jobsDBMu.Unlock()
+	job, ok := s.getJob(id)
+	if !ok {
+		return
+	}
+	items, err := json.Marshal(job.Items)
+	if err == nil {
+		err = dbgen.New(s.DB).UpsertJob(context.Background(), dbgen.UpsertJobParams{
+			ID:        job.ID,
+			Kind:      job.Kind,
+			ProjectID: job.ProjectID,
+			Status:    string(job.Status),
+			Completed: int64(job.Completed),
+			Total:     int64(job.Total),
+			Items:     string(items),
+			Error:     job.Error,
+			CreatedAt: job.CreatedAt,
+			UpdatedAt: job.UpdatedAt,
+		})
+	}
+	if err != nil {
+		slog.Warn("save job", "job", id, "error", err)
+	}
+}
+
+// restoreJobs loads the jobs saved before a restart, dropping ones older
+// than jobRetention. Jobs that were queued or running are marked failed,
+// since nothing will finish them, and finished items whose output file has
+// since disappeared are marked failed too.
+func (s *Server) restoreJobs(ctx context.Context) error {
+	q := dbgen.New(s.DB)
+	if err := q.DeleteJobsUpdatedBefore(ctx, time.Now().Add(-jobRetention)); err != nil {
+		return err
+	}
+	rows, err := q.ListJobs(ctx)
+	if err != nil {
+		return err
+	}
+
+	var interrupted []string
+	s.jobsMu.Lock()
+	for _, row := range rows {
+		job := &Job{
+			ID:        row.ID,
+			Kind:      row.Kind,
+			ProjectID: row.ProjectID,
+			Status:    JobStatus(row.Status),
+			Completed: int(row.Completed),
+			Total:     int(row.Total),
+			Error:     row.Error,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			changed:   make(chan struct{}),
+		}
+		if err := json.Unmarshal([]byte(row.Items), &job.Items); err != nil {
+			slog.Warn("restore job: bad items", "job", row.ID, "error", err)
+		}
+		if s.reconcileRestoredJob(job) {
+			interrupted = append(interrupted, job.ID)
+		}
+		s.jobs[job.ID] = job
+	}
+	s.jobsMu.Unlock()
+
+	for _, id := range interrupted {
+		s.saveJob(id)
+	}
+	if len(rows) > 0 {
+		slog.Info("restored jobs", "jobs", len(rows), "interrupted", len(interrupted))
+	}
+	return nil
+}
+
+// reconcileRestoredJob fixes up a job loaded from the database and reports
+// whether it changed.
+func (s *Server) reconcileRestoredJob(job *Job) bool {
+	changed := false
+	failed := 0
+	for i := range job.Items {
+		item := &job.Items[i]
+		switch {
+		case item.Status == JobQueued || item.Status == JobRunning:
+			item.Status, item.Error = JobFailed, errJobInterrupted
+			job.Completed++
+			changed = true
+		case item.Status == JobDone && strings.HasPrefix(item.Result, "/static/"):
+			if path, ok := s.staticFilePath(item.Result); ok {
+				if _, err := os.Stat(path); err != nil {
+					item.Status, item.Error = JobFailed, "the output file no longer exists"
+					changed = true
+				}
+			}
+		}
+		if item.Status == JobFailed {
+			failed++
+		}
+	}
+	if job.Status == JobQueued || job.Status == JobRunning {
+		job.Status = JobFailed
+		job.Error = errJobInterrupted
+		changed = true
+	} else if changed && failed > 0 {
+		job.Error = fmt.Sprintf("%d of %d items failed", failed, job.Total)
+		if failed == job.Total {
+			job.Status = JobFailed
+		}
+	}
+	if changed {
+		job.UpdatedAt = time.Now()
+	}
+	return changed
+}
@@ -0,0 +1,240 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultPreviewWidth is the width of a preview when none is asked for;
+	// the height follows the project's aspect ratio
+	defaultPreviewWidth = 480
+	// maxPreviewWidth keeps previews small enough to share
+	maxPreviewWidth = 1280
+	// defaultPreviewFrameDuration is how long each keyframe is shown, in
+	// seconds
+	defaultPreviewFrameDuration = 1.0
+	// previewFPS is the preview's frame rate; low, since frames only change
+	// between keyframes
+	previewFPS = 5
+)
+
+// previewFormats are the animated formats a preview can be encoded as.
+var previewFormats = []string{"gif", "webp"}
+
+// PreviewRequest configures an animated keyframe preview. Zero values pick
+// the defaults.
+type PreviewRequest struct {
+	// Format is "gif" (the default) or "webp"
+	Format string `json:"format"`
+	// Width and Height are the frame size; a missing height follows the
+	// project's resolution, or 16:9
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// FrameDuration is how long each keyframe is shown, in seconds
+	FrameDuration float64 `json:"frameDuration"`
+}
+
+// resolve fills in defaults, taking the aspect ratio from the project's
+// resolution, and validates the request.
+func (p PreviewRequest) resolve(projectResolution string) (PreviewRequest, error) {
+	if p.Format == "" {
+		p.Format = previewFormats[0]
+	}
+	if p.Width == 0 {
+		p.Width = defaultPreviewWidth
+	}
+	if p.FrameDuration == 0 {
+		p.FrameDuration = defaultPreviewFrameDuration
+	}
+	if p.Height == 0 {
+		aspect := mediaSize{Width: 16, Height: 9}
+		if size, ok := parseResolution(projectResolution); ok {
+			aspect = size
+		}
+		p.Height = int(math.Round(float64(p.Width) * float64(aspect.Height) / float64(aspect.Width)))
+	}
+	// Encoders want even dimensions
+	p.Width, p.Height = p.Width&^1, p.Height&^1
+
+	switch {
+	case !slices.Contains(previewFormats, p.Format):
+		return PreviewRequest{}, fmt.Errorf("unsupported preview format %q (supported: %s)", p.Format, strings.Join(previewFormats, ", "))
+	case p.Width < 16 || p.Width > maxPreviewWidth || p.Height < 16 || p.Height > maxPreviewWidth:
+		return PreviewRequest{}, fmt.Errorf("preview size must be between 16 and %d pixels a side, got %dx%d", maxPreviewWidth, p.Width, p.Height)
+	case p.FrameDuration < 0.1 || p.FrameDuration > 10:
+		return PreviewRequest{}, fmt.Errorf("frameDuration must be between 0.1 and 10 seconds, got %g", p.FrameDuration)
+	}
+	return p, nil
+}
+
+// previewArgs builds the ffmpeg arguments that turn frames into a looping
+// animation, each frame letterboxed to the preview size and held for the
+// frame duration. GIFs get a palette generated from the frames themselves.
+func previewArgs(frames []string, p PreviewRequest, outputPath string) []string {
+	fit := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease:flags=lanczos,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%d",
+		p.Width, p.Height, p.Width, p.Height, previewFPS)
+	args := []string{"-y"}
+	var parts []string
+	var labels string
+	for i, frame := range frames {
+		args = append(args, "-loop", "1", "-t", strconv.FormatFloat(p.FrameDuration, 'g', -1, 64), "-i", frame)
+		parts = append(parts, fmt.Sprintf("[%d:v]%s[f%d]", i, fit, i))
+		labels += fmt.Sprintf("[f%d]", i)
+	}
+	parts = append(parts, fmt.Sprintf("%sconcat=n=%d:v=1:a=0[seq]", labels, len(frames)))
+
+	if p.Format == "webp" {
+		parts = append(parts, "[seq]format=yuva420p[out]")
+		args = append(args, "-filter_complex", strings.Join(parts, ";"), "-map", "[out]",
+			"-c:v", "libwebp", "-lossless", "0", "-quality", "75")
+	} else {
+		parts = append(parts, "[seq]split[a][b];[a]palettegen=stats_mode=diff[p];[b][p]paletteuse=dither=bayer")
+		args = append(args, "-filter_complex", strings.Join(parts, ";"))
+	}
+	return append(args, "-loop", "0", outputPath)
+}
+
+var keyframeFilePattern = regexp.MustCompile(`^scene_(\d+)\.\w+$`)
+
+// previewFrames writes the project's scene keyframes, in scene order, into
+// dir and returns their paths. Scenes of a loaded project use their current
+// image; a project only on disk uses the files in its keyframes folder.
+func (s *Server) previewFrames(ctx context.Context, projectID, dir string) ([]string, error) {
+	s.mu.RLock()
+	var imageURLs []string
+	if project, ok := s.projects[projectID]; ok {
+		for _, scene := range project.Scenes {
+			imageURLs = append(imageURLs, scene.ImageURL)
+		}
+	}
+	s.mu.RUnlock()
+
+	var frames []string
+	if imageURLs != nil {
+		for i, imageURL := range imageURLs {
+			data, err := s.currentSceneImage(ctx, projectID, i+1, imageURL)
+			if err != nil {
+				// A scene without a keyframe yet is left out of the preview
+				continue
+			}
+			ext := imageExtension("data:" + http.DetectContentType(data) + ";base64,")
+			frame := filepath.Join(dir, fmt.Sprintf("frame_%03d%s", i+1, ext))
+			if err := os.WriteFile(frame, data, 0644); err != nil {
+				return nil, err
+			}
+			frames = append(frames, frame)
+		}
+		return frames, nil
+	}
+
+	projectPath, err := s.projectDir(projectID)
+	if err != nil {
+		return nil, err
+	}
+	keyframesDir := filepath.Join(projectPath, "keyframes")
+	entries, err := os.ReadDir(keyframesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	scenes := map[int]string{}
+	for _, entry := range entries {
+		match := keyframeFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil || !slices.Contains(imageExtensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+		n, _ := strconv.Atoi(match[1])
+		scenes[n] = filepath.Join(keyframesDir, entry.Name())
+	}
+	for _, n := range slices.Sorted(maps.Keys(scenes)) {
+		frames = append(frames, scenes[n])
+	}
+	return frames, nil
+}
+
+// HandlePreview renders the project's keyframes into a small looping GIF
+// or animated WebP for sharing, without needing any clips to be rendered,
+// and returns its URL.
+func (s *Server) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	var req PreviewRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req, maxJSONBody) {
+		return
+	}
+
+	projectID := r.PathValue("id")
+	projectPath, err := s.projectDir(projectID)
+	s.mu.RLock()
+	project, loaded := s.projects[projectID]
+	var resolution string
+	if loaded {
+		resolution = project.Resolution
+	}
+	s.mu.RUnlock()
+	if err != nil && !loaded {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	if req, err = req.resolve(resolution); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.ffmpeg.Available {
+		writeJSONError(w, http.StatusServiceUnavailable, "FFmpeg is not installed on this server; install ffmpeg and restart to make previews")
+		return
+	}
+
+	tmpDir, err := os.MkdirTemp("", tempDirPrefix+"-preview-")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create temp dir: "+err.Error())
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	frames, err := s.previewFrames(r.Context(), projectID, tmpDir)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read keyframes: "+err.Error())
+		return
+	}
+	if len(frames) == 0 {
+		writeJSONError(w, http.StatusConflict, "The project has no keyframes to preview yet")
+		return
+	}
+
+	outputPath := filepath.Join(tmpDir, "preview."+req.Format)
+	if _, err := s.runFFmpeg(r.Context(), previewArgs(frames, req, outputPath)); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to render preview: "+err.Error())
+		return
+	}
+	// Keep a copy with the project, if it has a folder, beside final.mp4
+	if projectPath != "" {
+		if err := copyFile(outputPath, filepath.Join(projectPath, "preview."+req.Format), 0644); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to save preview: "+err.Error())
+			return
+		}
+	}
+	previewURL, err := s.publishToStatic(outputPath, fmt.Sprintf("previews/%s.%s", projectID, req.Format))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to publish preview: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"url":           previewURL,
+		"format":        req.Format,
+		"width":         req.Width,
+		"height":        req.Height,
+		"frameDuration": req.FrameDuration,
+		"frames":        len(frames),
+	})
+}
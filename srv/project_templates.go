@@ -0,0 +1,113 @@
+package srv
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// ProjectTemplate is a reusable preset of project defaults (output format,
+// style, image provider) applied when a project is created.
+type ProjectTemplate struct {
+	Name          string `json:"name"`
+	Resolution    string `json:"resolution"`
+	FPS           int    `json:"fps"`
+	Codec         string `json:"codec"`
+	Style         string `json:"style"`
+	KeyframeCount int    `json:"keyframeCount"`
+	ImageProvider string `json:"imageProvider"`
+}
+
+func projectTemplateFromDB(t dbgen.ProjectTemplate) ProjectTemplate {
+	return ProjectTemplate{
+		Name:          t.Name,
+		Resolution:    t.Resolution,
+		FPS:           int(t.Fps),
+		Codec:         t.Codec,
+		Style:         t.Style,
+		KeyframeCount: int(t.KeyframeCount),
+		ImageProvider: t.ImageProvider,
+	}
+}
+
+// apply fills any zero-valued project settings from the template, leaving
+// values the client supplied explicitly untouched.
+func (t ProjectTemplate) apply(p *Project) {
+	if p.Resolution == "" {
+		p.Resolution = t.Resolution
+	}
+	if p.FPS == 0 {
+		p.FPS = t.FPS
+	}
+	if p.Codec == "" {
+		p.Codec = t.Codec
+	}
+	if p.Style == "" {
+		p.Style = t.Style
+	}
+	if p.KeyframeCount == 0 {
+		p.KeyframeCount = t.KeyframeCount
+	}
+	if p.ImageProvider == "" {
+		p.ImageProvider = t.ImageProvider
+	}
+}
+
+func (s *Server) HandleListTemplates(w http.ResponseWriter, r *http.Request) {
+	rows, err := dbgen.New(s.DB).ListProjectTemplates(r.Context())
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to list templates: "+err.Error())
+		return
+	}
+
+	templates := make([]ProjectTemplate, len(rows))
+	for i, row := range rows {
+		templates[i] = projectTemplateFromDB(row)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"templates": templates,
+	})
+}
+
+// HandleSaveTemplate creates a template or replaces the one with the same name.
+func (s *Server) HandleSaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var req ProjectTemplate
+	if !decodeJSON(w, r, &req, maxJSONBody) {
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeJSONError(w, http.StatusBadRequest, "Template name is required")
+		return
+	}
+	if req.FPS < 0 || req.KeyframeCount < 0 {
+		writeJSONError(w, http.StatusBadRequest, "fps and keyframeCount must not be negative")
+		return
+	}
+
+	now := time.Now()
+	err := dbgen.New(s.DB).UpsertProjectTemplate(r.Context(), dbgen.UpsertProjectTemplateParams{
+		Name:          req.Name,
+		Resolution:    req.Resolution,
+		Fps:           int64(req.FPS),
+		Codec:         req.Codec,
+		Style:         req.Style,
+		KeyframeCount: int64(req.KeyframeCount),
+		ImageProvider: req.ImageProvider,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save template: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
@@ -0,0 +1,304 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imageProviders is the registry of image provider names the generation
+// endpoints accept. "placehold" (and its alias "none") explicitly selects
+// placeholder art; an empty provider means the same.
+var imageProviders = []string{
+	"placehold",
+	"none",
+	"gemini",
+	"nanobananopro",
+	"midjourney",
+	"dalle",
+	"stability",
+	"leonardo",
+}
+
+// ImageProvider generates an image for a prompt and returns its URL, which
+// may be a data URL.
+type ImageProvider interface {
+	// Name is the provider's registry name
+	Name() string
+	Generate(ctx context.Context, prompt string, opts GenOptions) (string, error)
+}
+
+// newProviderRegistry maps every name in imageProviders to its
+// implementation, making requests with client. Providers without an
+// integration yet (gemini, nanobananopro, midjourney, leonardo) render
+// placeholder art.
+func newProviderRegistry(client *http.Client) map[string]ImageProvider {
+	registry := make(map[string]ImageProvider, len(imageProviders))
+	for _, name := range imageProviders {
+		registry[name] = PlaceholderProvider{}
+	}
+	registry["dalle"] = &DalleProvider{APIKey: os.Getenv("OPENAI_API_KEY"), Client: client}
+	registry["stability"] = &StabilityProvider{APIKey: os.Getenv("STABILITY_API_KEY"), Client: client}
+	return registry
+}
+
+// imageProvider looks a provider up by name; an empty name means placeholder
+// art. Unknown names get an error listing the supported providers.
+func (s *Server) imageProvider(name string) (ImageProvider, error) {
+	if name == "" {
+		name = "placehold"
+	}
+	if p, ok := s.providerRegistry[name]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown image provider %q (supported: %s)", name, strings.Join(imageProviders, ", "))
+}
+
+// PlaceholderProvider draws placehold.co cards colored and labelled by the
+// character or scene. It stands in for providers that aren't integrated and
+// is the fallback when a real provider's call fails.
+type PlaceholderProvider struct{}
+
+func (PlaceholderProvider) Name() string { return "placehold" }
+
+func (PlaceholderProvider) Generate(ctx context.Context, prompt string, opts GenOptions) (string, error) {
+	return placeholderImage(opts), nil
+}
+
+// placeholderImage is the placeholder art for a character (opts.Scene unset)
+// or a scene keyframe.
+func placeholderImage(opts GenOptions) string {
+	if opts.Scene == 0 {
+		return fmt.Sprintf("https://placehold.co/512x512/%s/ffffff?text=Character+Art", characterColor(opts.Character))
+	}
+	colors := []string{"1a1a2e", "16213e", "0f3460", "533483", "e94560", "2d4059", "3d5a80", "5c4d7d"}
+	color := colors[(opts.Scene-1)%len(colors)]
+	if opts.InitImage != nil {
+		return fmt.Sprintf("https://placehold.co/512x288/%s/ffffff?text=Scene+%d+Refined", color, opts.Scene)
+	}
+	return fmt.Sprintf("https://placehold.co/512x288/%s/ffffff?text=Scene+%d", color, opts.Scene)
+}
+
+// characterColor picks the placeholder background color for a character.
+func characterColor(index int) string {
+	colors := []string{"6366f1", "8b5cf6", "ec4899", "f43f5e", "f97316", "eab308", "22c55e", "14b8a6"}
+	return colors[((index-1)%len(colors)+len(colors))%len(colors)]
+}
+
+// providerErrContentFiltered is the ProviderError code for prompts or images
+// rejected by a provider's safety filter.
+const providerErrContentFiltered = "content_filtered"
+
+// ProviderError is a provider failure the client should see rather than have
+// papered over with a placeholder, such as a safety rejection.
+type ProviderError struct {
+	Provider string `json:"provider"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Provider, e.Code, e.Message)
+}
+
+// GenOptions carries the optional inputs of an image generation call.
+type GenOptions struct {
+	// References are character art images that keep characters consistent
+	References []CharacterRef
+	// InitImage, when set, makes the call img2img: the result is a variation
+	// of this image guided by the prompt
+	InitImage []byte
+	// Strength is the img2img denoising strength (0-1); low values keep the
+	// init image's composition
+	Strength float64
+	// Character is the index of the character being drawn, and Scene the
+	// 1-based number of the scene keyframe; placeholder art is labelled by them
+	Character int
+	Scene     int
+}
+
+// defaultProviderConcurrency caps in-flight image requests for providers
+// without their own entry in providerConcurrency.
+const defaultProviderConcurrency = 4
+
+// providerConcurrency is each provider's default limit on in-flight image
+// requests, sized to stay under its rate limits during batch generation.
+var providerConcurrency = map[string]int{
+	"dalle":      5,
+	"stability":  2,
+	"leonardo":   3,
+	"midjourney": 1,
+	"veo":        2,
+}
+
+// providerLimit returns how many image requests may be in flight at once for
+// a provider: the server's override if any, else the registry default.
+func (s *Server) providerLimit(provider string) int {
+	if n, ok := s.ProviderConcurrency[provider]; ok && n > 0 {
+		return n
+	}
+	if n, ok := providerConcurrency[provider]; ok {
+		return n
+	}
+	return defaultProviderConcurrency
+}
+
+// ParseProviderConcurrency parses a limit list like "dalle=5,stability=2".
+func ParseProviderConcurrency(spec string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("provider limit %q: want name=count", part)
+		}
+		name = strings.TrimSpace(name)
+		if err := validateImageProvider(name); err != nil || name == "" {
+			return nil, fmt.Errorf("provider limit %q: unknown image provider", part)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("provider limit %q: count must be a positive integer", part)
+		}
+		limits[name] = n
+	}
+	return limits, nil
+}
+
+// validateImageProvider returns an error listing the supported providers when
+// name isn't registered.
+func validateImageProvider(name string) error {
+	if name == "" {
+		return nil
+	}
+	for _, p := range imageProviders {
+		if name == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown image provider %q (supported: %s)", name, strings.Join(imageProviders, ", "))
+}
+
+// apiCheckTimeout bounds quick API calls such as key checks, which should
+// answer well within the shared client's timeout.
+const apiCheckTimeout = 10 * time.Second
+
+// providerCheck is a cheap authenticated request used to verify a provider's
+// API key without generating anything.
+type providerCheck struct {
+	envKey string
+	url    string
+	auth   func(req *http.Request, key string)
+}
+
+func bearerAuth(req *http.Request, key string) {
+	req.Header.Set("Authorization", "Bearer "+key)
+}
+
+func googleAuth(req *http.Request, key string) {
+	req.Header.Set("x-goog-api-key", key)
+}
+
+var providerChecks = map[string]providerCheck{
+	"dalle":         {envKey: "OPENAI_API_KEY", url: "https://api.openai.com/v1/models", auth: bearerAuth},
+	"stability":     {envKey: "STABILITY_API_KEY", url: "https://api.stability.ai/v1/user/account", auth: bearerAuth},
+	"leonardo":      {envKey: "LEONARDO_API_KEY", url: "https://cloud.leonardo.ai/api/rest/v1/me", auth: bearerAuth},
+	"gemini":        {envKey: "GEMINI_API_KEY", url: "https://generativelanguage.googleapis.com/v1beta/models", auth: googleAuth},
+	"nanobananopro": {envKey: "GEMINI_API_KEY", url: "https://generativelanguage.googleapis.com/v1beta/models", auth: googleAuth},
+}
+
+// HandleTestProvider checks that a provider's API key works, like
+// HandleGitHubTest does for GitHub. The key comes from the request body or,
+// if omitted, the provider's environment variable.
+func (s *Server) HandleTestProvider(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, err := s.imageProvider(name); err != nil || name == "" {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("unknown image provider %q (supported: %s)", name, strings.Join(imageProviders, ", ")))
+		return
+	}
+
+	var req struct {
+		APIKey string `json:"apiKey"`
+	}
+	if r.ContentLength != 0 {
+		if !decodeJSON(w, r, &req, maxJSONBody) {
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if name == "placehold" || name == "none" {
+		json.NewEncoder(w).Encode(map[string]any{
+			"success":  true,
+			"provider": name,
+			"message":  "Placeholder provider needs no API key",
+		})
+		return
+	}
+
+	check, ok := providerChecks[name]
+	if !ok {
+		json.NewEncoder(w).Encode(map[string]any{
+			"success":  false,
+			"provider": name,
+			"error":    "No API check is available for " + name,
+		})
+		return
+	}
+
+	key := req.APIKey
+	if key == "" {
+		key = os.Getenv(check.envKey)
+	}
+	if key == "" {
+		json.NewEncoder(w).Encode(map[string]any{
+			"success":  false,
+			"provider": name,
+			"error":    check.envKey + " is not set",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), apiCheckTimeout)
+	defer cancel()
+	apiReq, _ := http.NewRequestWithContext(ctx, "GET", check.url, nil)
+	check.auth(apiReq, key)
+
+	resp, err := s.httpClient.Do(apiReq)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]any{
+			"success":  false,
+			"provider": name,
+			"error":    "Failed to connect to " + name + ": " + err.Error(),
+		})
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg := fmt.Sprintf("%s request failed (status %d)", name, resp.StatusCode)
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			msg = fmt.Sprintf("%s authentication failed (status %d)", name, resp.StatusCode)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"success":  false,
+			"provider": name,
+			"error":    msg,
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  true,
+		"provider": name,
+	})
+}
@@ -0,0 +1,121 @@
+package srv
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is a per-client token bucket: a client may make Burst requests
+// at once, and earns them back at PerMinute. A zero PerMinute disables it.
+type RateLimit struct {
+	PerMinute float64
+	Burst     int
+}
+
+// defaultGenerateRateLimit bounds how fast one client can start image and
+// video generations, which cost provider credits and ffmpeg time.
+var defaultGenerateRateLimit = RateLimit{PerMinute: 30, Burst: 10}
+
+// ParseRateLimit parses "30/10" as 30 requests a minute with bursts of 10;
+// "0" disables limiting.
+func ParseRateLimit(s string) (RateLimit, error) {
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(s), "/")
+	perMinute, err := strconv.ParseFloat(rate, 64)
+	if err != nil || perMinute < 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate %q: want requests per minute, e.g. 30/10", s)
+	}
+	limit := RateLimit{PerMinute: perMinute, Burst: max(1, int(math.Ceil(perMinute)))}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst < 1 {
+			return RateLimit{}, fmt.Errorf("invalid burst %q: want a positive count", burst)
+		}
+	}
+	return limit, nil
+}
+
+// maxRateBuckets is how many clients are tracked before idle ones, whose
+// buckets have refilled, are forgotten.
+const maxRateBuckets = 10000
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter holds the token buckets of every client it has seen.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+// allow takes a token from key's bucket at time now. When the bucket is
+// empty it returns false and how long until the next token.
+func (l *rateLimiter) allow(limit RateLimit, key string, now time.Time) (bool, time.Duration) {
+	if limit.PerMinute <= 0 {
+		return true, 0
+	}
+	perSecond := limit.PerMinute / 60
+	burst := float64(max(1, limit.Burst))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buckets) >= maxRateBuckets {
+		l.forgetIdle(perSecond, burst, now)
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*perSecond)
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) / perSecond * float64(time.Second))
+}
+
+// forgetIdle drops buckets that have refilled, which behave the same as a
+// new one. The caller holds l.mu.
+func (l *rateLimiter) forgetIdle(perSecond, burst float64, now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*perSecond >= burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// clientIP is the address a request came from, for per-client limits.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimited wraps generation endpoints so each client IP is held to
+// GenerateRateLimit, answering 429 with Retry-After once it runs out.
+func (s *Server) rateLimited(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := s.generateLimiter.allow(s.GenerateRateLimit, clientIP(r), time.Now())
+		if !ok {
+			seconds := max(1, int(math.Ceil(wait.Seconds())))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("Too many generation requests; try again in %ds", seconds))
+			return
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,338 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultRenderResolution is the frame size for projects without a
+// resolution whose clips can't be probed.
+const defaultRenderResolution = "1920x1080"
+
+// finalRenderFPS is the common frame rate every clip is converted to.
+const finalRenderFPS = 30
+
+// finalAudioFormat is the sample format every clip's audio, or the silence
+// standing in for it, is converted to before the clips are joined.
+const finalAudioFormat = "aformat=sample_fmts=fltp:sample_rates=48000:channel_layouts=stereo"
+
+// finalRenderArgs builds the ffmpeg arguments that join the plan's clips, in
+// plan order, into outputPath. Each clip is its own input, normalized
+// through a scale/pad/setsar/fps chain and joined with the concat filter, so
+// clips that differ in codec, size, frame rate or timebase still join
+// cleanly. If any clip has audio, clips without it get silence of their
+// length so the narration stays in sync. The plan's music bed is mixed under
+// the clip audio at its volume (ducked if the plan asks for it). A non-empty
+// subtitlesPath is burned in with the plan's caption style.
+func finalRenderArgs(plan *RenderPlan, outputPath, subtitlesPath string) ([]string, error) {
+	size, ok := parseResolution(plan.Resolution)
+	if !ok {
+		size, _ = parseResolution(defaultRenderResolution)
+	}
+	clipAudio := plan.clipAudio()
+
+	var args, filters []string
+	var segments strings.Builder
+	for i, clip := range plan.Clips {
+		args = append(args, "-i", clip.Path)
+		filters = append(filters, fmt.Sprintf("[%d:v]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%d,format=yuv420p,setpts=PTS-STARTPTS[v%d]",
+			i, size.Width, size.Height, size.Width, size.Height, finalRenderFPS, i))
+		fmt.Fprintf(&segments, "[v%d]", i)
+		if !clipAudio {
+			continue
+		}
+		if clip.Audio {
+			filters = append(filters, fmt.Sprintf("[%d:a]%s,asetpts=PTS-STARTPTS[a%d]", i, finalAudioFormat, i))
+		} else {
+			filters = append(filters, fmt.Sprintf("anullsrc=r=48000:cl=stereo,atrim=duration=%g,%s[a%d]", clip.Duration, finalAudioFormat, i))
+		}
+		fmt.Fprintf(&segments, "[a%d]", i)
+	}
+	joined := fmt.Sprintf("%sconcat=n=%d:v=1:a=0[joined]", segments.String(), len(plan.Clips))
+	if clipAudio {
+		joined = fmt.Sprintf("%sconcat=n=%d:v=1:a=1[joined][clips]", segments.String(), len(plan.Clips))
+	}
+	filters = append(filters, joined)
+
+	video := "[joined]"
+	if subtitlesPath != "" && plan.Captions != nil {
+		video += subtitlesFilter(subtitlesPath, *plan.Captions) + ","
+	}
+	filters = append(filters, video+"format=yuv420p[outv]")
+
+	var music *PlannedAudio
+	for i := range plan.AudioTracks {
+		if plan.AudioTracks[i].Kind == "music" {
+			music = &plan.AudioTracks[i]
+		}
+	}
+	audioMap := ""
+	switch {
+	case music != nil:
+		args = append(args, audioBedInputArgs(music.Path)...)
+		filters = append(filters, audioBedFilter(len(plan.Clips), plan.TotalDuration, music.Fade, music.Volume, "music"))
+		if clipAudio {
+			mix, err := audioMixFilter("clips", "music", plan.Ducking)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, mix)
+			audioMap = "[aout]"
+		} else {
+			audioMap = "[music]"
+		}
+	case clipAudio:
+		audioMap = "[clips]"
+	}
+
+	args = append(append([]string{"-y"}, args...), "-filter_complex", strings.Join(filters, ";"), "-map", "[outv]")
+	if audioMap != "" {
+		args = append(args, "-map", audioMap, "-c:a", "aac")
+	}
+	args = append(args, videoEncodeArgs(false)...)
+	args = append(args, "-movflags", "+faststart", outputPath)
+	return args, nil
+}
+
+// probeHasAudio reports whether a media file has an audio stream.
+func probeHasAudio(ctx context.Context, path string) bool {
+	output, err := runFFprobe(ctx, "-v", "error",
+		"-select_streams", "a",
+		"-show_entries", "stream=index",
+		"-of", "csv=p=0",
+		path,
+	)
+	return err == nil && strings.TrimSpace(string(output)) != ""
+}
+
+// defaultPosterTime is how far into the final video, in seconds, its poster
+// frame is taken from.
+const defaultPosterTime = 1.0
+
+// posterArgs builds the ffmpeg arguments that grab the frame at seconds into
+// videoPath as a JPEG.
+func posterArgs(videoPath, posterPath string, seconds float64) []string {
+	return []string{"-y", "-ss", fmt.Sprintf("%g", seconds), "-i", videoPath, "-frames:v", "1", "-q:v", "2", posterPath}
+}
+
+// posterTime keeps the poster timestamp inside a video of the given length,
+// taking the middle frame of videos too short for it.
+func posterTime(seconds, duration float64) float64 {
+	if duration > 0 && seconds >= duration {
+		return duration / 2
+	}
+	return seconds
+}
+
+// extractPoster saves the frame at seconds into videoPath as posterPath,
+// writing it under a temporary name first so a failed run leaves nothing.
+func (s *Server) extractPoster(ctx context.Context, videoPath, posterPath string, seconds float64) error {
+	tmpPath := filepath.Join(filepath.Dir(posterPath), ".rendering-"+filepath.Base(posterPath))
+	defer os.Remove(tmpPath) // no-op once renamed
+	if _, err := s.runFFmpeg(ctx, posterArgs(videoPath, tmpPath, seconds)); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, posterPath)
+}
+
+// HandleRenderFinal joins the project's scene clips into final.mp4 in a
+// background job, following the render plan (the stored sequence, else
+// storyboard order). The body may pass a sequence, as for render-plan, which
+// is remembered as the project's render sequence, captions to make from the scene narration, and posterTime, the second the
+// poster frame is taken from. Poll /api/jobs/{jobId}; the finished video is
+// served at videoUrl (/static/videos/final_<id>.mp4, one per project) and its
+// poster at posterUrl, which is missing if the frame couldn't be extracted.
+func (s *Server) HandleRenderFinal(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Sequence   []int           `json:"sequence"`
+		Captions   *CaptionOptions `json:"captions"`
+		PosterTime *float64        `json:"posterTime"`
+	}
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req, maxJSONBody) {
+		return
+	}
+	posterAt := defaultPosterTime
+	if req.PosterTime != nil {
+		if *req.PosterTime < 0 {
+			writeJSONError(w, http.StatusBadRequest, "posterTime must not be negative")
+			return
+		}
+		posterAt = *req.PosterTime
+	}
+	var captions *CaptionOptions
+	if req.Captions != nil {
+		c, err := req.Captions.withDefaults()
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		captions = &c
+	}
+
+	projectID := r.PathValue("id")
+	projectPath, err := s.projectDir(projectID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	plan, err := s.buildRenderPlan(r.Context(), projectID, req.Sequence)
+	if err != nil {
+		if errors.Is(err, errInvalidSequence) {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to plan render: "+err.Error())
+		return
+	}
+	if len(plan.Clips) == 0 {
+		writeJSONError(w, http.StatusNotFound, "No scene clips found")
+		return
+	}
+	// Remember an explicit sequence as the project's delivery order
+	if req.Sequence != nil {
+		s.mu.Lock()
+		if project, ok := s.projects[projectID]; ok {
+			project.RenderSequence = slices.Clone(req.Sequence)
+		}
+		s.mu.Unlock()
+	}
+	if captions != nil {
+		if buildSRT(plan.Clips) == "" {
+			plan.Warnings = append(plan.Warnings, "captions were requested but no scene in the render has narration")
+		} else {
+			plan.Captions = captions
+		}
+	}
+
+	videoURL := finalVideoURL(projectID)
+	job := s.newJob("render-final", projectID, []JobItem{{Kind: "final", Index: 0}})
+	go s.runJob(job.ID, "", []jobTask{func() (string, error) {
+		if err := s.renderFinal(s.jobsCtx, plan, projectPath, posterAt, func(percent float64) {
+			s.setJobProgress(job.ID, 0, percent)
+		}); err != nil {
+			return "", err
+		}
+		return videoURL, nil
+	}})
+
+	resp := map[string]any{
+		"jobId":     job.ID,
+		"statusUrl": "/api/jobs/" + job.ID,
+		"videoUrl":  videoURL,
+		"posterUrl": finalPosterURL(projectID),
+		"plan":      plan,
+	}
+	if plan.Captions != nil && plan.Captions.Mode == "sidecar" {
+		resp["captionsUrl"] = finalCaptionsURL(projectID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// finalVideoURL is where a project's final render is served. Each project
+// has its own, so concurrent renders of different projects don't collide.
+func finalVideoURL(projectID string) string {
+	return "/static/videos/final_" + projectID + ".mp4"
+}
+
+// finalCaptionsURL is where the sidecar captions for a project's final render
+// are served.
+func finalCaptionsURL(projectID string) string {
+	return "/static/videos/final_" + projectID + ".srt"
+}
+
+// finalPosterURL is where the poster frame of a project's final render is
+// served, beside the render itself.
+func finalPosterURL(projectID string) string {
+	return "/static/videos/poster_" + projectID + ".jpg"
+}
+
+// renderFinal renders the plan to final.mp4 in the project folder and copies
+// it to finalVideoURL for playback, along with a poster.jpg taken posterAt
+// seconds in. A poster that can't be made is logged and skipped.
+func (s *Server) renderFinal(ctx context.Context, plan *RenderPlan, projectPath string, posterAt float64, onProgress func(percent float64)) error {
+	// Captions go next to the video as final.srt; burned captions render
+	// from a temp copy whose path is safe to put in a filter
+	var subtitlesPath string
+	if plan.Captions != nil {
+		srt := buildSRT(plan.Clips)
+		if plan.Captions.Mode == "sidecar" {
+			srtPath := filepath.Join(projectPath, "final.srt")
+			if _, err := writeFileAtomic(srtPath, strings.NewReader(srt), 0644); err != nil {
+				return err
+			}
+			if _, err := s.publishToStatic(srtPath, strings.TrimPrefix(finalCaptionsURL(plan.ProjectID), "/static/")); err != nil {
+				return err
+			}
+		} else {
+			tmp, err := os.CreateTemp("", tempDirPrefix+"-captions-*.srt")
+			if err != nil {
+				return err
+			}
+			defer os.Remove(tmp.Name())
+			_, err = tmp.WriteString(srt)
+			if cerr := tmp.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+			subtitlesPath = tmp.Name()
+		}
+	}
+
+	// Render to a hidden file renamed into place when done, so a render
+	// that fails or is stopped never leaves a truncated final.mp4
+	outputPath := filepath.Join(projectPath, "final.mp4")
+	renderPath := filepath.Join(projectPath, ".final.rendering.mp4")
+	defer os.Remove(renderPath) // no-op once renamed
+	args, err := finalRenderArgs(plan, renderPath, subtitlesPath)
+	if err != nil {
+		return err
+	}
+	_, err = s.runFFmpegProgress(ctx, args, func(p ffmpegProgress) {
+		if p.Done {
+			onProgress(100)
+		} else if plan.TotalDuration > 0 {
+			onProgress(min(100, 100*p.OutTime.Seconds()/plan.TotalDuration))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(renderPath, outputPath); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return err
+	}
+	if _, err := s.publishToStatic(outputPath, strings.TrimPrefix(finalVideoURL(plan.ProjectID), "/static/")); err != nil {
+		return err
+	}
+
+	// The poster is optional: without one the video still plays, so a stale
+	// poster from an earlier render is removed rather than left mismatched
+	posterPath := filepath.Join(projectPath, "poster.jpg")
+	staticPoster, _ := s.staticFilePath(finalPosterURL(plan.ProjectID))
+	if err := s.extractPoster(ctx, outputPath, posterPath, posterTime(posterAt, plan.TotalDuration)); err != nil {
+		slog.Warn("failed to extract poster frame", "project", plan.ProjectID, "error", err)
+		os.Remove(posterPath)
+		os.Remove(staticPoster)
+	} else if _, err := s.publishToStatic(posterPath, strings.TrimPrefix(finalPosterURL(plan.ProjectID), "/static/")); err != nil {
+		slog.Warn("failed to publish poster frame", "project", plan.ProjectID, "error", err)
+		os.Remove(staticPoster)
+	}
+	slog.Info("rendered final video", "project", plan.ProjectID, "clips", len(plan.Clips), "duration", plan.TotalDuration, "size", info.Size())
+	return nil
+}
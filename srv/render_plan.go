@@ -0,0 +1,413 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultClipDuration is assumed for clips whose length can't be probed; it
+// matches the default duration of generate-video.
+const defaultClipDuration = 5.0
+
+// RenderPlan is the exact sequence a final render would produce: which clips
+// in what order, how they join, which audio plays, and the total length.
+type RenderPlan struct {
+	ProjectID string `json:"projectId"`
+	// Sequence is the scene indices rendered, in output order
+	Sequence      []int          `json:"sequence"`
+	Resolution    string         `json:"resolution,omitempty"`
+	Clips         []PlannedClip  `json:"clips"`
+	AudioTracks   []PlannedAudio `json:"audioTracks"`
+	TotalDuration float64        `json:"totalDuration"`
+	// Ducking is applied only when the clips have audio (their narration)
+	// and there's a music bed to duck under it
+	Ducking *DuckingOptions `json:"ducking,omitempty"`
+	// Captions are made from the clips' narration when requested
+	Captions *CaptionOptions `json:"captions,omitempty"`
+	Warnings []string        `json:"warnings"`
+}
+
+// PlannedClip is one scene's clip in a render plan.
+type PlannedClip struct {
+	SceneIndex int     `json:"sceneIndex"`
+	SceneID    string  `json:"sceneId,omitempty"`
+	Path       string  `json:"path"`
+	Start      float64 `json:"start"`
+	Duration   float64 `json:"duration"`
+	// Estimated is set when the duration couldn't be probed
+	Estimated bool       `json:"estimated,omitempty"`
+	Size      *mediaSize `json:"size,omitempty"`
+	FPS       float64    `json:"fps,omitempty"`
+	Codec     string     `json:"codec,omitempty"`
+	// Audio is set when the clip has an audio track, e.g. its narration
+	Audio bool `json:"audio,omitempty"`
+	// Transition is how this clip joins the previous one
+	Transition string `json:"transition"`
+	// Narration is the scene's text, used for captions
+	Narration string `json:"narration,omitempty"`
+}
+
+// PlannedAudio is an audio track mixed into the render.
+type PlannedAudio struct {
+	Kind     string  `json:"kind"`
+	Path     string  `json:"path"`
+	Start    float64 `json:"start"`
+	Duration float64 `json:"duration,omitempty"`
+	// Loop repeats the track until Duration is filled
+	Loop bool `json:"loop,omitempty"`
+	// Fade is the fade-in and fade-out length in seconds
+	Fade float64 `json:"fade,omitempty"`
+	// Volume scales the track; 0 leaves it at its own level
+	Volume float64 `json:"volume,omitempty"`
+}
+
+// parseResolution parses "1920x1080" into a size.
+func parseResolution(res string) (mediaSize, bool) {
+	w, h, ok := strings.Cut(strings.ToLower(res), "x")
+	if !ok {
+		return mediaSize{}, false
+	}
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return mediaSize{}, false
+	}
+	return mediaSize{Width: width, Height: height}, true
+}
+
+// probeVideoDuration asks ffprobe for a video's length in seconds.
+func probeVideoDuration(ctx context.Context, path string) (float64, error) {
+	output, err := runFFprobe(ctx, "-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+}
+
+// VideoMeta is what ffprobe reports about a clip: its length in seconds and
+// its first video stream's size, frame rate and codec.
+type VideoMeta struct {
+	Duration float64 `json:"duration"`
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	FPS      float64 `json:"fps"`
+	Codec    string  `json:"codec"`
+}
+
+// size is the clip's frame size.
+func (m VideoMeta) size() mediaSize {
+	return mediaSize{Width: m.Width, Height: m.Height}
+}
+
+// probeVideo asks ffprobe for a clip's metadata.
+func probeVideo(ctx context.Context, path string) (VideoMeta, error) {
+	output, err := runFFprobe(ctx, "-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=codec_name,width,height,avg_frame_rate,r_frame_rate:format=duration",
+		"-print_format", "json",
+		path,
+	)
+	if err != nil {
+		return VideoMeta{}, err
+	}
+	meta, err := parseVideoMeta(output)
+	if err != nil {
+		return VideoMeta{}, fmt.Errorf("%w in %s", err, path)
+	}
+	return meta, nil
+}
+
+// parseVideoMeta reads ffprobe's JSON output.
+func parseVideoMeta(output []byte) (VideoMeta, error) {
+	var probe struct {
+		Streams []struct {
+			CodecName    string `json:"codec_name"`
+			Width        int    `json:"width"`
+			Height       int    `json:"height"`
+			AvgFrameRate string `json:"avg_frame_rate"`
+			RFrameRate   string `json:"r_frame_rate"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return VideoMeta{}, fmt.Errorf("parse ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return VideoMeta{}, errors.New("no video stream")
+	}
+	stream := probe.Streams[0]
+	meta := VideoMeta{Width: stream.Width, Height: stream.Height, Codec: stream.CodecName}
+	// The average rate is the real one for variable frame rate clips; some
+	// containers only report the base rate
+	if meta.FPS = parseFrameRate(stream.AvgFrameRate); meta.FPS == 0 {
+		meta.FPS = parseFrameRate(stream.RFrameRate)
+	}
+	meta.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	return meta, nil
+}
+
+// parseFrameRate parses ffprobe's "30000/1001" frame rates, rounded to two
+// places; an unknown rate ("0/0") is 0.
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	if !found {
+		den = "1"
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return math.Round(n/d*100) / 100
+}
+
+var errInvalidSequence = errors.New("invalid render sequence")
+
+// buildRenderPlan assembles the render plan for a project without rendering.
+// sequence lists scene indices in output order; scenes may be omitted or
+// repeated. A nil sequence falls back to the project's last-used sequence,
+// then to story order (or, for projects known only from disk, every scene
+// clip in its videos folder in index order). It changes nothing.
+func (s *Server) buildRenderPlan(ctx context.Context, projectID string, sequence []int) (*RenderPlan, error) {
+	projectPath, err := s.projectDir(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &RenderPlan{
+		ProjectID:   projectID,
+		Clips:       []PlannedClip{},
+		AudioTracks: []PlannedAudio{},
+		Warnings:    []string{},
+	}
+
+	var sceneIDs, narrations []string
+	var stored []int
+	var musicVolume float64
+	inMemory := false
+	s.mu.RLock()
+	if project, ok := s.projects[projectID]; ok {
+		inMemory = true
+		plan.Resolution = project.Resolution
+		if project.Ducking != nil && project.Ducking.Enabled {
+			d, err := project.Ducking.withDefaults()
+			if err == nil {
+				plan.Ducking = &d
+			}
+		}
+		for _, scene := range project.Scenes {
+			sceneIDs = append(sceneIDs, scene.ID)
+			narrations = append(narrations, scene.Narration)
+		}
+		stored = slices.Clone(project.RenderSequence)
+		musicVolume = project.MusicVolume
+	}
+	s.mu.RUnlock()
+
+	// available[i] is scene i's clip; Path is empty when it has none
+	var available []PlannedClip
+	videosDir := filepath.Join(projectPath, "videos")
+	if inMemory {
+		for i, id := range sceneIDs {
+			clip := PlannedClip{SceneIndex: i, SceneID: id, Narration: narrations[i]}
+			clipPath := filepath.Join(videosDir, findSceneVideo(videosDir, i+1))
+			if _, err := os.Stat(clipPath); err == nil {
+				clip.Path = clipPath
+			}
+			available = append(available, clip)
+		}
+	} else {
+		clips, err := listSceneClips(projectPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, clip := range clips {
+			if clip.index < 1 {
+				continue
+			}
+			for len(available) < clip.index {
+				available = append(available, PlannedClip{SceneIndex: len(available)})
+			}
+			available[clip.index-1].Path = clip.path
+		}
+	}
+
+	// A scene in the sequence must exist; for projects known only from disk
+	// that means it must have a clip
+	validate := func(order []int) error {
+		for _, i := range order {
+			if i < 0 || i >= len(available) || (!inMemory && available[i].Path == "") {
+				return fmt.Errorf("%w: no scene at index %d", errInvalidSequence, i)
+			}
+		}
+		return nil
+	}
+
+	order := sequence
+	if order != nil {
+		if err := validate(order); err != nil {
+			return nil, err
+		}
+	} else if stored != nil {
+		if err := validate(stored); err == nil {
+			order = stored
+		} else {
+			plan.Warnings = append(plan.Warnings, "saved render sequence no longer matches the scenes; using storyboard order")
+		}
+	}
+	if order == nil {
+		order = []int{}
+		for i, clip := range available {
+			if inMemory || clip.Path != "" {
+				order = append(order, i)
+			}
+		}
+	}
+	plan.Sequence = order
+
+	for _, i := range order {
+		clip := available[i]
+		if clip.Path == "" {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("scene %d (%s) has no video clip", i+1, clip.SceneID))
+			continue
+		}
+		plan.Clips = append(plan.Clips, clip)
+	}
+	if len(plan.Clips) == 0 {
+		plan.Warnings = append(plan.Warnings, "project has no video clips to render")
+	}
+
+	want, haveWant := parseResolution(plan.Resolution)
+	// The first probed clip sets the codec and frame rate the others are
+	// compared to; the render normalizes mismatches, but they're reported
+	// since they cost quality
+	var first *PlannedClip
+	for i := range plan.Clips {
+		clip := &plan.Clips[i]
+		clip.Transition = "cut"
+		clip.Start = plan.TotalDuration
+
+		clip.Audio = probeHasAudio(ctx, clip.Path)
+		meta, err := probeVideo(ctx, clip.Path)
+		if err == nil && meta.Duration > 0 {
+			clip.Duration = meta.Duration
+		} else {
+			clip.Duration = defaultClipDuration
+			clip.Estimated = true
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("scene %d: could not probe duration, assuming %gs", clip.SceneIndex+1, defaultClipDuration))
+		}
+		plan.TotalDuration += clip.Duration
+		if err != nil {
+			continue
+		}
+
+		size := meta.size()
+		clip.Size, clip.FPS, clip.Codec = &size, meta.FPS, meta.Codec
+		if !haveWant {
+			// Without a project resolution, the first clip sets the frame size
+			want, haveWant = size, true
+			plan.Resolution = fmt.Sprintf("%dx%d", size.Width, size.Height)
+		} else if size != want {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("scene %d is %dx%d, expected %s", clip.SceneIndex+1, size.Width, size.Height, plan.Resolution))
+		}
+		if first == nil {
+			first = clip
+			continue
+		}
+		if clip.Codec != first.Codec {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("scene %d is %s, but scene %d is %s", clip.SceneIndex+1, clip.Codec, first.SceneIndex+1, first.Codec))
+		}
+		if clip.FPS != first.FPS {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("scene %d is %gfps, but scene %d is %gfps", clip.SceneIndex+1, clip.FPS, first.SceneIndex+1, first.FPS))
+		}
+	}
+
+	// The project's music bed runs under the whole movie, looped or trimmed
+	// to its length
+	if bed := findAudioBed(projectPath); bed != "" && plan.TotalDuration > 0 {
+		plan.AudioTracks = append(plan.AudioTracks, PlannedAudio{
+			Kind:     "music",
+			Path:     bed,
+			Duration: plan.TotalDuration,
+			Loop:     true,
+			Fade:     math.Min(audioBedFade, plan.TotalDuration/4),
+			Volume:   musicVolume,
+		})
+	}
+
+	if plan.Ducking != nil && !(plan.clipAudio() && plan.hasAudio("music")) {
+		plan.Warnings = append(plan.Warnings, "ducking is enabled but needs a clip with narration audio and a music track; it will be skipped")
+	}
+
+	return plan, nil
+}
+
+// hasAudio reports whether the plan includes an audio track of the given kind.
+func (p *RenderPlan) hasAudio(kind string) bool {
+	for _, track := range p.AudioTracks {
+		if track.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// clipAudio reports whether the clips' own audio goes into the render: it
+// does if any clip has some, with silence standing in for the rest.
+func (p *RenderPlan) clipAudio() bool {
+	return slices.ContainsFunc(p.Clips, func(clip PlannedClip) bool { return clip.Audio })
+}
+
+// parseSequence parses a comma-separated list of scene indices like "2,0,0".
+func parseSequence(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var sequence []int
+	for _, part := range strings.Split(value, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a scene index", errInvalidSequence, part)
+		}
+		sequence = append(sequence, i)
+	}
+	return sequence, nil
+}
+
+// HandleRenderPlan returns the render plan for a project so the sequence can
+// be previewed (and debugged) before anything is rendered. An optional
+// ?sequence=2,0,1 sets the output order; only a render remembers it.
+func (s *Server) HandleRenderPlan(w http.ResponseWriter, r *http.Request) {
+	sequence, err := parseSequence(r.URL.Query().Get("sequence"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	plan, err := s.buildRenderPlan(r.Context(), r.PathValue("id"), sequence)
+	if errors.Is(err, errInvalidSequence) {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
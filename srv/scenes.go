@@ -0,0 +1,508 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"srv.exe.dev/db/dbgen"
+)
+
+// sceneFileDirs are the project folders whose scene files are named by
+// position, scene_N.<ext> with N counting from 1.
+var sceneFileDirs = []string{"keyframes", "images", "videos"}
+
+// sceneFilePattern matches a positional scene file, but not the frames a
+// render downloads beside it (scene_1_first.png).
+var sceneFilePattern = regexp.MustCompile(`^scene_(\d+)(\.\w+)$`)
+
+var (
+	errProjectNotFound = errors.New("project not found")
+	errSceneNotFound   = errors.New("scene not found")
+	errInvalidOrder    = errors.New("invalid scene order")
+)
+
+// rearrangeScenes replaces a project's scenes with the list arrange returns,
+// along with from, where from[i] is the old position of the scene now at i,
+// or -1 for a new scene.
+// Everything else keyed by position follows: the render sequence, recorded
+// generations, and in the project folder the scene files and project.json.
+// Files of scenes left out are deleted, or with keepRemoved moved into a
+// .removed folder under their scene ID. It returns the new scenes.
+//
+// The project's folder lock is held throughout, so rearrangements of one
+// project, saves and clips landing in its folder happen one at a time. If
+// the files can't be renumbered, those already moved are moved back and the
+// scenes return to their old order.
+func (s *Server) rearrangeScenes(projectID string, keepRemoved bool, arrange func(project *Project) ([]Scene, []int, error)) ([]Scene, error) {
+	// A project may not have a folder yet; then there's nothing to renumber
+	projectPath, err := s.projectDir(projectID)
+	hasDir := err == nil
+	if hasDir {
+		lock := s.projectLock(projectPath)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	s.mu.Lock()
+	project, exists := s.projects[projectID]
+	if !exists {
+		s.mu.Unlock()
+		return nil, errProjectNotFound
+	}
+	oldScenes, oldSequence := project.Scenes, project.RenderSequence
+	scenes, from, err := arrange(project)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	var removedIDs map[int]string
+	if keepRemoved {
+		moved := newPositions(from)
+		removedIDs = make(map[int]string)
+		for i, scene := range oldScenes {
+			if _, kept := moved[i]; !kept {
+				removedIDs[i] = scene.ID
+			}
+		}
+	}
+	project.Scenes = scenes
+	project.RenderSequence = remapPositions(oldSequence, from)
+	scenes = slices.Clone(scenes)
+	s.mu.Unlock()
+
+	keys := []string{projectID}
+	if hasDir {
+		keys = append(keys, projectPath)
+		renames, err := renumberSceneFiles(projectPath, from, len(oldScenes), removedIDs)
+		if err == nil {
+			if err = reorderSavedScenes(projectPath, from, len(oldScenes), scenes); err != nil {
+				renames.undo()
+			}
+		}
+		if err != nil {
+			s.restoreSceneOrder(projectID, oldScenes, oldSequence, from)
+			return nil, fmt.Errorf("renumber scene files: %w", err)
+		}
+		renames.finish()
+	}
+	s.remapGenerations(keys, from)
+	s.broadcast(projectID, ProjectEvent{Type: "scenes", Scenes: scenes})
+	return scenes, nil
+}
+
+// restoreSceneOrder undoes a rearrangement whose files couldn't be
+// renumbered, putting the scenes back in their old order. Edits made to
+// them in the meantime are kept.
+func (s *Server) restoreSceneOrder(projectID string, oldScenes []Scene, oldSequence, from []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	project, exists := s.projects[projectID]
+	if !exists {
+		return
+	}
+	restored := slices.Clone(oldScenes)
+	for i, old := range from {
+		if old >= 0 && i < len(project.Scenes) && project.Scenes[i].ID == oldScenes[old].ID {
+			restored[old] = project.Scenes[i]
+		}
+	}
+	project.Scenes = restored
+	project.RenderSequence = oldSequence
+}
+
+// newPositions inverts from: it maps each old position still in use to its
+// new one.
+func newPositions(from []int) map[int]int {
+	positions := make(map[int]int, len(from))
+	for i, old := range from {
+		if old >= 0 {
+			positions[old] = i
+		}
+	}
+	return positions
+}
+
+// remapPositions moves zero-based scene positions to where from put them,
+// dropping any whose scene is gone.
+func remapPositions(positions, from []int) []int {
+	if positions == nil {
+		return nil
+	}
+	moved := newPositions(from)
+	remapped := []int{}
+	for _, old := range positions {
+		if i, ok := moved[old]; ok {
+			remapped = append(remapped, i)
+		}
+	}
+	return remapped
+}
+
+// removedScenesDir is where the files of a removed scene are kept, in each
+// scene file folder; being hidden, it's left out of exports.
+const removedScenesDir = ".removed"
+
+// fileRenames records the renames made while renumbering scene files, so
+// they can be undone if a later step fails. Files to delete are only moved
+// aside until finish.
+type fileRenames struct {
+	done    [][2]string
+	discard []string
+}
+
+// rename moves from to to and records it.
+func (f *fileRenames) rename(from, to string) error {
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	f.done = append(f.done, [2]string{from, to})
+	return nil
+}
+
+// undo moves every renamed file back, most recent first.
+func (f *fileRenames) undo() {
+	for i := len(f.done) - 1; i >= 0; i-- {
+		if err := os.Rename(f.done[i][1], f.done[i][0]); err != nil {
+			slog.Warn("failed to restore scene file", "path", f.done[i][0], "error", err)
+		}
+	}
+}
+
+// finish deletes the files that were set aside for deletion.
+func (f *fileRenames) finish() {
+	for _, path := range f.discard {
+		if err := os.Remove(path); err != nil {
+			slog.Warn("failed to delete removed scene file", "path", path, "error", err)
+		}
+	}
+}
+
+// renumberSceneFiles renames the positional scene files in projectPath to
+// match from. Files are first moved aside, then to their new numbers, so
+// swapping two scenes never overwrites either. Files of scenes no longer in
+// from are moved to removedScenesDir as <scene ID><ext> when removedIDs
+// names the scene, and deleted by finish otherwise, as are strays numbered
+// past the old scenes that a new scene would pick up. If a rename fails the
+// ones already made are undone.
+func renumberSceneFiles(projectPath string, from []int, oldCount int, removedIDs map[int]string) (_ *fileRenames, err error) {
+	renames := &fileRenames{}
+	defer func() {
+		if err != nil {
+			renames.undo()
+		}
+	}()
+	moved := newPositions(from)
+	last := max(oldCount, len(from))
+	for _, dir := range sceneFileDirs {
+		dir = filepath.Join(projectPath, dir)
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		type rename struct{ aside, to string }
+		var pending []rename
+		for _, entry := range entries {
+			match := sceneFilePattern.FindStringSubmatch(entry.Name())
+			if match == nil || entry.IsDir() {
+				continue
+			}
+			n, _ := strconv.Atoi(match[1])
+			if n < 1 || n > last {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			aside := filepath.Join(dir, ".renumber."+entry.Name())
+			i, kept := moved[n-1]
+			id, keep := removedIDs[n-1]
+			switch {
+			case !kept && keep:
+				if err := os.MkdirAll(filepath.Join(dir, removedScenesDir), 0755); err != nil {
+					return nil, err
+				}
+				if err := renames.rename(path, filepath.Join(dir, removedScenesDir, filepath.Base(id)+match[2])); err != nil {
+					return nil, err
+				}
+			case !kept:
+				if err := renames.rename(path, aside); err != nil {
+					return nil, err
+				}
+				renames.discard = append(renames.discard, aside)
+			case i != n-1:
+				if err := renames.rename(path, aside); err != nil {
+					return nil, err
+				}
+				pending = append(pending, rename{aside, filepath.Join(dir, fmt.Sprintf("scene_%d%s", i+1, match[2]))})
+			}
+		}
+		for _, r := range pending {
+			if err := renames.rename(r.aside, r.to); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return renames, nil
+}
+
+// reorderSavedScenes rearranges the scenes in a saved project.json to match
+// from, renaming their scene_N file references and bumping its version so a
+// client holding the old order must reload before saving. New scenes are
+// saved as they are in scenes. A project.json whose scenes don't line up
+// with the project's is left alone.
+func reorderSavedScenes(projectPath string, from []int, oldCount int, scenes []Scene) error {
+	jsonPath := filepath.Join(projectPath, "project.json")
+	data, err := os.ReadFile(jsonPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var saved map[string]any
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("parse project.json: %w", err)
+	}
+	savedScenes, _ := saved["scenes"].([]any)
+	if len(savedScenes) != oldCount {
+		slog.Warn("saved scenes don't match the project; not reordering project.json", "path", jsonPath, "saved", len(savedScenes), "scenes", oldCount)
+		return nil
+	}
+
+	reordered := make([]any, 0, len(from))
+	for i, old := range from {
+		if old < 0 {
+			reordered = append(reordered, map[string]any{
+				"id":          scenes[i].ID,
+				"narration":   scenes[i].Narration,
+				"imagePrompt": scenes[i].ImagePrompt,
+				"status":      scenes[i].Status,
+			})
+			continue
+		}
+		scene, _ := savedScenes[old].(map[string]any)
+		for _, field := range []string{"imageFile", "videoFile"} {
+			name, _ := scene[field].(string)
+			if match := sceneFilePattern.FindStringSubmatch(name); match != nil {
+				scene[field] = fmt.Sprintf("scene_%d%s", i+1, match[2])
+			}
+		}
+		reordered = append(reordered, scene)
+	}
+	saved["scenes"] = reordered
+	version, _ := saved[projectVersionKey].(float64)
+	saved[projectVersionKey] = int64(version) + 1
+
+	data, err = json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = writeFileAtomic(jsonPath, bytes.NewReader(data), 0644)
+	return err
+}
+
+// remapGenerations moves recorded scene generations to their scenes' new
+// positions, dropping those of removed scenes, so a later save recovers
+// them into the right scenes. Like recordGeneration it uses a background
+// context, so a client disconnecting can't leave the records half moved.
+func (s *Server) remapGenerations(keys []string, from []int) {
+	ctx := context.Background()
+	moved := newPositions(from)
+	q := dbgen.New(s.DB)
+	for _, key := range keys {
+		results, err := q.ListGenerationResults(ctx, key)
+		if err != nil {
+			slog.Warn("list generation results", "project", key, "error", err)
+			continue
+		}
+		if len(results) == 0 {
+			continue
+		}
+		if err := q.DeleteGenerationResults(ctx, key); err != nil {
+			slog.Warn("clear generation results", "project", key, "error", err)
+			continue
+		}
+		for _, result := range results {
+			index := int(result.ItemIndex)
+			if result.Kind == generatedSceneImage || result.Kind == generatedSceneVideo {
+				i, ok := moved[index]
+				if !ok {
+					continue
+				}
+				index = i
+			}
+			err := q.UpsertGenerationResult(ctx, dbgen.UpsertGenerationResultParams{
+				ProjectKey: key,
+				Kind:       result.Kind,
+				ItemIndex:  int64(index),
+				Url:        result.Url,
+				Provider:   result.Provider,
+				CreatedAt:  result.CreatedAt,
+			})
+			if err != nil {
+				slog.Warn("record generation", "project", key, "kind", result.Kind, "index", index, "error", err)
+			}
+		}
+	}
+}
+
+// HandleReorderScenes puts a project's scenes in the order of the scene IDs
+// in the body, either a bare array or {"sceneIds": [...]}, which must list
+// every scene exactly once. Scene files on disk are renumbered so scene_N
+// still belongs to the Nth scene.
+func (s *Server) HandleReorderScenes(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	if !decodeJSON(w, r, &body, maxJSONBody) {
+		return
+	}
+	var req struct {
+		SceneIDs []string `json:"sceneIds"`
+	}
+	target := any(&req)
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		target = &req.SceneIDs
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Body must be an array of scene IDs: "+err.Error())
+		return
+	}
+
+	scenes, err := s.rearrangeScenes(r.PathValue("id"), false, func(project *Project) ([]Scene, []int, error) {
+		scenes := project.Scenes
+		if len(req.SceneIDs) != len(scenes) {
+			return nil, nil, fmt.Errorf("%w: got %d scene IDs for %d scenes", errInvalidOrder, len(req.SceneIDs), len(scenes))
+		}
+		reordered := make([]Scene, len(scenes))
+		from := make([]int, len(scenes))
+		seen := make(map[string]bool, len(scenes))
+		for i, id := range req.SceneIDs {
+			old := slices.IndexFunc(scenes, func(scene Scene) bool { return scene.ID == id })
+			if old < 0 {
+				return nil, nil, fmt.Errorf("%w: unknown scene %q", errInvalidOrder, id)
+			}
+			if seen[id] {
+				return nil, nil, fmt.Errorf("%w: scene %q is listed twice", errInvalidOrder, id)
+			}
+			seen[id] = true
+			reordered[i], from[i] = scenes[old], old
+		}
+		return reordered, from, nil
+	})
+	if !writeRearrangeError(w, err) {
+		return
+	}
+	slog.Info("reordered scenes", "project", r.PathValue("id"), "scenes", len(scenes))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"scenes":  scenes,
+	})
+}
+
+// HandleDeleteScene removes a scene, addressed by ID or zero-based index,
+// and renumbers the scene files after it. The removed scene's files are
+// moved into a .removed folder beside them, or with ?deleteFiles=true
+// deleted. It returns the remaining scenes.
+func (s *Server) HandleDeleteScene(w http.ResponseWriter, r *http.Request) {
+	ref := r.PathValue("scene")
+	deleteFiles := r.URL.Query().Get("deleteFiles") == "true"
+
+	var removed Scene
+	scenes, err := s.rearrangeScenes(r.PathValue("id"), !deleteFiles, func(project *Project) ([]Scene, []int, error) {
+		i, found := sceneIndex(project, ref)
+		if !found {
+			return nil, nil, errSceneNotFound
+		}
+		removed = project.Scenes[i]
+		from := make([]int, 0, len(project.Scenes)-1)
+		for old := range project.Scenes {
+			if old != i {
+				from = append(from, old)
+			}
+		}
+		return slices.Delete(slices.Clone(project.Scenes), i, i+1), from, nil
+	})
+	if !writeRearrangeError(w, err) {
+		return
+	}
+	slog.Info("deleted scene", "project", r.PathValue("id"), "scene", removed.ID, "deleteFiles", deleteFiles)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"removed": removed.ID,
+		"scenes":  scenes,
+	})
+}
+
+// HandleInsertScene adds a blank scene after the scene named by
+// afterSceneId, or at the end without one, and returns it with the new scene
+// list. Scene files after it are renumbered to make room, so the new scene
+// starts without an image or clip.
+func (s *Server) HandleInsertScene(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AfterSceneID string `json:"afterSceneId"`
+	}
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req, maxJSONBody) {
+		return
+	}
+
+	scene := Scene{ID: randomID("scene_"), Status: SceneDraft}
+	scenes, err := s.rearrangeScenes(r.PathValue("id"), false, func(project *Project) ([]Scene, []int, error) {
+		at := len(project.Scenes)
+		if req.AfterSceneID != "" {
+			i := slices.IndexFunc(project.Scenes, func(scene Scene) bool { return scene.ID == req.AfterSceneID })
+			if i < 0 {
+				return nil, nil, fmt.Errorf("%w: no scene %q to insert after", errInvalidOrder, req.AfterSceneID)
+			}
+			at = i + 1
+		}
+		from := make([]int, 0, len(project.Scenes)+1)
+		for old := range project.Scenes {
+			from = append(from, old)
+		}
+		return slices.Insert(slices.Clone(project.Scenes), at, scene), slices.Insert(from, at, -1), nil
+	})
+	if !writeRearrangeError(w, err) {
+		return
+	}
+	position := slices.IndexFunc(scenes, func(other Scene) bool { return other.ID == scene.ID })
+	slog.Info("inserted scene", "project", r.PathValue("id"), "scene", scene.ID, "position", position)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  true,
+		"scene":    scene,
+		"position": position,
+		"scenes":   scenes,
+	})
+}
+
+// writeRearrangeError answers a failed rearrangeScenes with the matching
+// status and reports whether err was nil.
+func writeRearrangeError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errProjectNotFound):
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+	case errors.Is(err, errSceneNotFound):
+		writeJSONError(w, http.StatusNotFound, "Scene not found")
+	case errors.Is(err, errInvalidOrder):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeJSONError(w, http.StatusInternalServerError, "Scenes were updated but their files couldn't be renumbered: "+err.Error())
+	}
+	return false
+}
@@ -1,49 +1,173 @@
 package srv
 
 import (
+	"bytes"
+	"cmp"
+	"context"
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
 	"log/slog"
+	"maps"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
 
+	"golang.org/x/sync/errgroup"
 	"srv.exe.dev/db"
+	"srv.exe.dev/db/dbgen"
 )
 
 type Server struct {
-	DB           *sql.DB
-	Hostname     string
-	TemplatesDir string
-	StaticDir    string
+	DB                  *sql.DB
+	Hostname            string
+	TemplatesDir        string
+	StaticDir           string
+	ProjectsRoot        string
+	// SafeMode disables endpoints that take raw filesystem paths or run git,
+	// and moving, copying or deleting project folders on disk, for shared
+	// deployments where only ID-based project access is allowed.
+	SafeMode            bool
+	// StaticCachePolicy picks Cache-Control for /static/ files; first match wins
+	StaticCachePolicy   []CacheRule
+	// FFmpegRetries is how many extra attempts a transient FFmpeg failure gets
+	FFmpegRetries       int
+	// FFmpegTimeout bounds each FFmpeg run; the process is killed after it
+	FFmpegTimeout       time.Duration
+	// MaxUploadSize bounds each uploaded video, in bytes
+	MaxUploadSize       int64
+	// MaxImageDownload and MaxVideoDownload bound each remote image or video
+	// saved into a project, in bytes
+	MaxImageDownload    int64
+	MaxVideoDownload    int64
+	// DownloadHosts, when set, limits remote downloads to these hosts and
+	// their subdomains
+	DownloadHosts       []string
+	// AllowPrivateHosts lets remote downloads reach loopback and private
+	// addresses, for development against local servers; off by default
+	AllowPrivateHosts   bool
+	// CORSOrigins are the origins allowed to call the API cross-origin
+	// ("*" for any); empty means same-origin only
+	CORSOrigins         []string
+	// SourcePath is the git checkout HandleGitHubPush commits and pushes
+	SourcePath          string
+	// AccessLogLevel is the level request logs are written at; Info by
+	// default, so raising the logger's level above it silences them
+	AccessLogLevel      slog.Level
+	// ProviderConcurrency overrides the registry's per-provider limit on
+	// in-flight image requests
+	ProviderConcurrency map[string]int
+	// GenerateRateLimit holds each client IP to a token bucket on the image
+	// and video generation endpoints; a zero PerMinute disables it
+	GenerateRateLimit   RateLimit
+	// ShutdownTimeout is how long a shutdown waits for in-flight requests
+	// and jobs before stopping them
+	ShutdownTimeout     time.Duration
+	// AllowCustomFilters lets generate-video append user-supplied FFmpeg
+	// filter steps; off by default
+	AllowCustomFilters  bool
+	// Veo generates scene clips; without an API key clips are placeholders
+	Veo                 *VeoClient
 
 	// In-memory store for projects (for now)
 	mu       sync.RWMutex
 	projects map[string]*Project
+
+	// Background jobs (bulk regeneration, renders)
+	jobsMu sync.RWMutex
+	jobs   map[string]*Job
+	// jobsDBMu serializes saving jobs to the database
+	jobsDBMu sync.Mutex
+
+	// jobsCtx is the context jobs run under; cancelJobs stops them when a
+	// shutdown's deadline passes
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+	// draining is set once shutdown begins, refusing new work
+	draining   atomic.Bool
+
+	// Storyboard WebSocket clients by project ID
+	collabMu    sync.Mutex
+	collabConns map[string][]*collabConn
+
+	// httpClient makes every outbound request but downloads of
+	// client-supplied URLs, which go through downloadClient
+	httpClient     *http.Client
+	downloadClient *http.Client
+
+	// Image providers by name, and per-provider slots bounding concurrent
+	// image requests
+	providerRegistry map[string]ImageProvider
+	providerMu       sync.Mutex
+	providerSlots    map[string]chan struct{}
+
+	// generateLimiter tracks clients against GenerateRateLimit
+	generateLimiter *rateLimiter
+
+	// Slots bounding concurrent ffmpeg processes
+	ffmpegSlots chan struct{}
+	// ffmpeg records whether ffmpeg was found at startup, and its version
+	ffmpeg ffmpegStatus
+
+	// Per-project-directory locks so loads never observe a save in progress
+	pathLocksMu sync.Mutex
+	pathLocks   map[string]*sync.RWMutex
 }
 
 type Project struct {
-	ID            string         `json:"id"`
-	StoryPrompt   string         `json:"storyPrompt"`
-	Characters    []Character    `json:"characters"`
+	ID             string          `json:"id"`
+	StoryPrompt    string          `json:"storyPrompt"`
+	Characters     []Character     `json:"characters"`
 	ArtImages  []ArtImages `json:"artImages"`
-	Keyframes     []Keyframe     `json:"keyframes"`
-	Scenes        []Scene        `json:"scenes"`
-	ImageProvider string         `json:"imageProvider"`
+	Keyframes      []Keyframe      `json:"keyframes"`
+	Scenes         []Scene         `json:"scenes"`
+	ImageProvider  string          `json:"imageProvider"`
+	Resolution     string          `json:"resolution,omitempty"`
+	FPS            int             `json:"fps,omitempty"`
+	Codec          string          `json:"codec,omitempty"`
+	Style          string          `json:"style,omitempty"`
+	KeyframeCount  int             `json:"keyframeCount,omitempty"`
+	// Path is the on-disk project folder, when it lives outside ProjectsRoot/{id}
+	Path           string          `json:"path,omitempty"`
+	StyleVersions  []StyleVersion  `json:"styleVersions,omitempty"`
+	// Ducking lowers background music under narration in the final render
+	Ducking        *DuckingOptions `json:"ducking,omitempty"`
+	// RenderSequence is the last scene order used for the final render
+	RenderSequence []int           `json:"renderSequence,omitempty"`
+	// AudioBed is the music bed file in the project's audio dir
+	AudioBed       string          `json:"audioBed,omitempty"`
+	// MusicVolume scales the music bed in the final render; 0 leaves it as is
+	MusicVolume    float64         `json:"musicVolume,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
 }
 
 type Character struct {
 	Index       int    `json:"index"`
 	Description string `json:"description"`
+	// Voice is the TTS voice used for narration attributed to this character
+	Voice       string `json:"voice,omitempty"`
 }
 
 type ArtImages struct {
@@ -52,24 +176,127 @@ type ArtImages struct {
 }
 
 type Keyframe struct {
-	Description string `json:"description"`
+	Description      string          `json:"description"`
+	// CharacterWeights maps character index to how strongly its reference
+	// should influence this scene. Missing characters default to 1.
+	CharacterWeights map[int]float64 `json:"characterWeights,omitempty"`
+	// Speaker is the index of the character narrating; 0 means the narrator
+	Speaker          int             `json:"speaker,omitempty"`
 }
 
 type Scene struct {
-	ID          string `json:"id"`
-	Narration   string `json:"narration"`
-	ImagePrompt string `json:"imagePrompt"`
-	ImageURL    string `json:"imageUrl"`
+	ID               string          `json:"id"`
+	Narration        string          `json:"narration"`
+	ImagePrompt      string          `json:"imagePrompt"`
+	ImageURL         string          `json:"imageUrl"`
+	VideoURL         string          `json:"videoUrl,omitempty"`
+	Status           SceneStatus     `json:"status"`
+	// Locked scenes are skipped by bulk regeneration
+	Locked           bool            `json:"locked"`
+	CharacterWeights map[int]float64 `json:"characterWeights,omitempty"`
+	Speaker          int             `json:"speaker,omitempty"`
+	// NarrationStart delays the narration after the cut, and NarrationPadding
+	// keeps silence before the scene ends, both in seconds
+	NarrationStart   float64         `json:"narrationStart,omitempty"`
+	NarrationPadding float64         `json:"narrationPadding,omitempty"`
+	// Duration is how long the scene's clip should run, in seconds; 0
+	// leaves it to the generator
+	Duration         int             `json:"duration,omitempty"`
+	// ImageSize is the keyframe's pixel size, nil while it can't be read
+	// locally (e.g. a remote placeholder)
+	ImageSize        *mediaSize      `json:"imageSize,omitempty"`
+}
+
+// maxSceneDuration bounds a scene's requested clip length, in seconds.
+const maxSceneDuration = 60
+
+// validateSceneDuration checks a requested clip length; 0 means unset.
+func validateSceneDuration(duration int) error {
+	if duration < 0 || duration > maxSceneDuration {
+		return fmt.Errorf("duration must be between 1 and %d seconds, or 0 for the default, got %d", maxSceneDuration, duration)
+	}
+	return nil
+}
+
+// SceneStatus is where a scene is in the generation pipeline.
+type SceneStatus string
+
+const (
+	SceneDraft      SceneStatus = "draft"
+	SceneImageReady SceneStatus = "image-ready"
+	SceneVideoReady SceneStatus = "video-ready"
+	SceneFailed     SceneStatus = "failed"
+)
+
+// sceneStatusFor derives a scene's status from the media it has. A failed
+// scene stays failed until a video is produced for it.
+func sceneStatusFor(current SceneStatus, hasImage, hasVideo bool) SceneStatus {
+	switch {
+	case hasVideo:
+		return SceneVideoReady
+	case current == SceneFailed:
+		return SceneFailed
+	case hasImage:
+		return SceneImageReady
+	default:
+		return SceneDraft
+	}
+}
+
+// DefaultNarratorVoice is the TTS voice for narration not attributed to a
+// character, or attributed to one without a voice of their own.
+const DefaultNarratorVoice = "narrator"
+
+// narrationVoice picks the TTS voice for a scene's narration.
+func narrationVoice(characters []Character, scene Scene) string {
+	if scene.Speaker != 0 {
+		for _, char := range characters {
+			if char.Index == scene.Speaker && char.Voice != "" {
+				return char.Voice
+			}
+		}
+	}
+	return DefaultNarratorVoice
 }
 
-func New(dbPath, hostname string) (*Server, error) {
+// New creates a server backed by the database at dbPath. corsOrigins lists
+// the browser origins allowed to call the API cross-origin; with none, the
+// API is same-origin only.
+func New(dbPath, hostname string, corsOrigins ...string) (*Server, error) {
 	_, thisFile, _, _ := runtime.Caller(0)
 	baseDir := filepath.Dir(thisFile)
+	httpClient := newHTTPClient()
 	srv := &Server{
-		Hostname:     hostname,
-		TemplatesDir: filepath.Join(baseDir, "templates"),
-		StaticDir:    filepath.Join(baseDir, "static"),
-		projects:     make(map[string]*Project),
+		Hostname:          hostname,
+		TemplatesDir:      filepath.Join(baseDir, "templates"),
+		StaticDir:         filepath.Join(baseDir, "static"),
+		ProjectsRoot:      filepath.Join(filepath.Dir(baseDir), "projects"),
+		SourcePath:        filepath.Dir(baseDir),
+		StaticCachePolicy: defaultStaticCachePolicy,
+		FFmpegRetries:     defaultFFmpegRetries,
+		FFmpegTimeout:     defaultFFmpegTimeout,
+		CORSOrigins:       corsOrigins,
+		MaxUploadSize:     defaultMaxUploadSize,
+		MaxImageDownload:  defaultMaxImageDownload,
+		MaxVideoDownload:  defaultMaxVideoDownload,
+		GenerateRateLimit: defaultGenerateRateLimit,
+		ShutdownTimeout:   defaultShutdownTimeout,
+		Veo:               &VeoClient{APIKey: os.Getenv("GEMINI_API_KEY"), Client: httpClient},
+		projects:          make(map[string]*Project),
+		jobs:              make(map[string]*Job),
+		collabConns:       make(map[string][]*collabConn),
+		httpClient:        httpClient,
+		providerRegistry:  newProviderRegistry(httpClient),
+		providerSlots:     make(map[string]chan struct{}),
+		generateLimiter:   newRateLimiter(),
+		ffmpegSlots:       make(chan struct{}, ffmpegWorkers()),
+		pathLocks:         make(map[string]*sync.RWMutex),
+		ffmpeg:            checkFFmpeg(),
+	}
+	srv.downloadClient = newDownloadClient(func() bool { return srv.AllowPrivateHosts })
+	srv.jobsCtx, srv.cancelJobs = context.WithCancel(context.Background())
+	if !srv.ffmpeg.Available {
+		slog.Warn("ffmpeg not found; video generation is disabled", "error", srv.ffmpeg.Error)
 	}
 	if err := srv.setUpDatabase(dbPath); err != nil {
 		return nil, err
@@ -87,12 +314,10 @@ func (s *Server) HandleHome(w http.ResponseWriter, r *http.Request) {
 func (s *Server) HandleStoryboard(w http.ResponseWriter, r *http.Request) {
 	projectID := r.PathValue("id")
 	
-	s.mu.RLock()
-	project, exists := s.projects[projectID]
-	s.mu.RUnlock()
+	project, exists := s.projectSnapshot(projectID)
 	
 	if !exists {
-		http.Error(w, "Project not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "Project not found")
 		return
 	}
 	
@@ -104,35 +329,81 @@ func (s *Server) HandleStoryboard(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) HandleCreateProject(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		StoryPrompt   string         `json:"storyPrompt"`
-		Characters    []Character    `json:"characters"`
+		StoryPrompt   string          `json:"storyPrompt"`
+		Characters    []Character     `json:"characters"`
 		ArtImages  []ArtImages `json:"artImages"`
-		Keyframes     []Keyframe     `json:"keyframes"`
-		ImageProvider string         `json:"imageProvider"`
+		Keyframes     []Keyframe      `json:"keyframes"`
+		ImageProvider string          `json:"imageProvider"`
+		Template      string          `json:"template"`
+		Resolution    string          `json:"resolution"`
+		FPS           int             `json:"fps"`
+		Codec         string          `json:"codec"`
+		Style         string          `json:"style"`
+		KeyframeCount int             `json:"keyframeCount"`
+		Ducking       *DuckingOptions `json:"ducking"`
+		// AutoScenes fills in canned default scenes when no keyframes are
+		// given; set it to false to start with no scenes instead
+		AutoScenes    *bool           `json:"autoScenes"`
 	}
 	
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req, maxMediaJSONBody) {
 		return
 	}
-	
-	// Generate a simple project ID
-	projectID := fmt.Sprintf("proj_%d", len(s.projects)+1)
+	if _, err := s.imageProvider(req.ImageProvider); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Ducking != nil {
+		if _, err := req.Ducking.withDefaults(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 	
 	// Generate scenes using keyframes, characters, and character art for consistency
-	scenes := generateScenesWithCharacters(req.Keyframes, req.StoryPrompt, req.Characters, req.ArtImages)
+	scenes := []Scene{}
+	if len(req.Keyframes) > 0 || req.AutoScenes == nil || *req.AutoScenes {
+		scenes = generateScenesWithCharacters(req.Keyframes, req.StoryPrompt, req.Characters, req.ArtImages)
+	}
 	
 	project := &Project{
-		ID:            projectID,
 		StoryPrompt:   req.StoryPrompt,
 		Characters:    req.Characters,
 		ArtImages:  req.ArtImages,
 		Keyframes:     req.Keyframes,
 		Scenes:        scenes,
 		ImageProvider: req.ImageProvider,
+		Resolution:    req.Resolution,
+		FPS:           req.FPS,
+		Codec:         req.Codec,
+		Style:         req.Style,
+		KeyframeCount: req.KeyframeCount,
+		Ducking:       req.Ducking,
+		CreatedAt:     time.Now(),
+	}
+
+	// Fill unset settings from the requested template
+	if req.Template != "" {
+		tmpl, err := dbgen.New(s.DB).ProjectTemplateWithName(r.Context(), req.Template)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusBadRequest, "Unknown template: "+req.Template)
+			return
+		} else if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to load template: "+err.Error())
+			return
+		}
+		projectTemplateFromDB(tmpl).apply(project)
+		if _, err := s.imageProvider(project.ImageProvider); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Template "+req.Template+": "+err.Error())
+			return
+		}
 	}
 	
+	// Pick the ID and register the project under one lock so concurrent
+	// creates can't claim the same ID
 	s.mu.Lock()
+	projectID := s.newProjectID()
+	project.ID = projectID
 	s.projects[projectID] = project
 	s.mu.Unlock()
 	
@@ -143,15 +414,101 @@ func (s *Server) HandleCreateProject(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// newProjectID returns a random, URL-safe project ID that isn't used by any
+// in-memory project or project folder. The caller must hold s.mu.
+func (s *Server) newProjectID() string {
+	for {
+		id := randomID("proj_")
+		if _, taken := s.projects[id]; taken {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(s.ProjectsRoot, id)); err == nil {
+			continue
+		}
+		return id
+	}
+}
+
+// ProjectSummary is a project's entry in the project list.
+type ProjectSummary struct {
+	ID           string    `json:"id"`
+	StoryPrompt  string    `json:"storyPrompt"`
+	SceneCount   int       `json:"sceneCount"`
+	ThumbnailURL string    `json:"thumbnailUrl,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// defaultProjectListLimit and maxProjectListLimit bound one page of the
+// project list.
+const (
+	defaultProjectListLimit = 100
+	maxProjectListLimit     = 1000
+)
+
+// HandleListProjects lists the known projects, newest first. ?limit and
+// ?offset page through them. The thumbnail is the first scene's image,
+// unless it is an inline data URL, which would bloat the list.
+func (s *Server) HandleListProjects(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := pageParams(w, r, defaultProjectListLimit, maxProjectListLimit)
+	if !ok {
+		return
+	}
+
+	s.mu.RLock()
+	projects := make([]ProjectSummary, 0, len(s.projects))
+	for _, project := range s.projects {
+		summary := ProjectSummary{
+			ID:          project.ID,
+			StoryPrompt: project.StoryPrompt,
+			SceneCount:  len(project.Scenes),
+			CreatedAt:   project.CreatedAt,
+		}
+		if len(project.Scenes) > 0 && !strings.HasPrefix(project.Scenes[0].ImageURL, "data:") {
+			summary.ThumbnailURL = project.Scenes[0].ImageURL
+		}
+		projects = append(projects, summary)
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(projects, func(a, b ProjectSummary) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	total := len(projects)
+	projects = projects[min(offset, total):min(offset+limit, total)]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"projects": projects,
+		"total":    total,
+	})
+}
+
+// projectSnapshot returns a copy of a project that is safe to read outside
+// s.mu. Handlers such as HandleUpdateScene edit scenes in place under the
+// lock, so the slices are copied too.
+func (s *Server) projectSnapshot(id string) (*Project, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	project, ok := s.projects[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *project
+	snapshot.Characters = slices.Clone(project.Characters)
+	snapshot.ArtImages = slices.Clone(project.ArtImages)
+	snapshot.Keyframes = slices.Clone(project.Keyframes)
+	snapshot.Scenes = slices.Clone(project.Scenes)
+	snapshot.StyleVersions = slices.Clone(project.StyleVersions)
+	return &snapshot, true
+}
+
 func (s *Server) HandleGetProject(w http.ResponseWriter, r *http.Request) {
 	projectID := r.PathValue("id")
 	
-	s.mu.RLock()
-	project, exists := s.projects[projectID]
-	s.mu.RUnlock()
+	project, exists := s.projectSnapshot(projectID)
 	
 	if !exists {
-		http.Error(w, "Project not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, "Project not found")
 		return
 	}
 	
@@ -159,178 +516,1484 @@ func (s *Server) HandleGetProject(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(project)
 }
 
-type ArtImagesRequest struct {
-	Characters []struct {
-		Index       int    `json:"index"`
-		Description string `json:"description"`
-	} `json:"characters"`
-	Provider string `json:"provider"`
+// projectLock returns the lock guarding the files of a project directory.
+// Writers (saves) hold it exclusively; HandleLoadProject holds it shared.
+func (s *Server) projectLock(projectPath string) *sync.RWMutex {
+	key := filepath.Clean(projectPath)
+	if abs, err := filepath.Abs(key); err == nil {
+		key = abs
+	}
+
+	s.pathLocksMu.Lock()
+	defer s.pathLocksMu.Unlock()
+	lock, ok := s.pathLocks[key]
+	if !ok {
+		lock = &sync.RWMutex{}
+		s.pathLocks[key] = lock
+	}
+	return lock
 }
 
-type ArtImagesResult struct {
-	Index    int    `json:"index"`
-	ImageURL string `json:"imageUrl"`
-	Prompt   string `json:"prompt"`
+// projectDir returns the on-disk folder for a project ID. A project created in
+// this session may carry an explicit Path; otherwise the ID must name a folder
+// directly under ProjectsRoot.
+func (s *Server) projectDir(id string) (string, error) {
+	s.mu.RLock()
+	project, exists := s.projects[id]
+	s.mu.RUnlock()
+	if exists && project.Path != "" {
+		return project.Path, nil
+	}
+
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid project id %q", id)
+	}
+	dir := filepath.Join(s.ProjectsRoot, id)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("project %q is not a directory", id)
+	}
+	return dir, nil
 }
 
-func (s *Server) HandleGenerateArtImages(w http.ResponseWriter, r *http.Request) {
-	var req ArtImagesRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
+// projectIDForPath returns the ID of the project kept in the resolved folder
+// dir: a registered project whose folder it is, else the folder's name when
+// it sits directly under ProjectsRoot, as projectDir would find it. It
+// returns "" for a folder that isn't a project's.
+func (s *Server) projectIDForPath(dir string) string {
+	s.mu.RLock()
+	paths := make(map[string]string)
+	for id, project := range s.projects {
+		if project.Path != "" {
+			paths[id] = project.Path
+		}
+	}
+	s.mu.RUnlock()
+	for id, path := range paths {
+		if resolved, err := resolveSymlinks(path); err == nil && resolved == dir {
+			return id
+		}
+	}
+	if root, err := resolveProjectPath(s.ProjectsRoot, "."); err == nil && dir != root && filepath.Dir(dir) == root {
+		return filepath.Base(dir)
 	}
+	return ""
+}
 
-	// Generate art for each character
-	// For now, use placeholder images - will integrate real providers later
-	results := make([]ArtImagesResult, len(req.Characters))
-	colors := []string{"6366f1", "8b5cf6", "ec4899", "f43f5e", "f97316", "eab308", "22c55e", "14b8a6"}
-	
-	for i, char := range req.Characters {
-		colorIdx := (char.Index - 1) % len(colors)
-		// In production, this would call the actual image generation API
-		imageURL := generateCharacterImage(char.Description, req.Provider, colors[colorIdx])
-		results[i] = ArtImagesResult{
-			Index:    char.Index,
-			ImageURL: imageURL,
-			Prompt:   char.Description,
+var errPathOutsideRoot = errors.New("path is outside the projects root")
+
+// resolveProjectPath maps a client-supplied project path onto disk, rejecting
+// anything that escapes root. Relative paths are taken relative to root, and
+// symlinks are resolved on the existing part of the path so a link inside the
+// root can't be used to reach outside it.
+func resolveProjectPath(root, userPath string) (string, error) {
+	if userPath == "" {
+		return "", errors.New("path is required")
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	realRoot, err := resolveSymlinks(absRoot)
+	if err != nil {
+		return "", err
+	}
+
+	path := userPath
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(absRoot, path)
+	}
+	realPath, err := resolveSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(realRoot, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errPathOutsideRoot
+	}
+	return realPath, nil
+}
+
+// isBaseName reports whether name is a plain file name: no directories, no
+// "..", and not hidden. Client-supplied file names inside a project must be.
+func isBaseName(name string) bool {
+	return name != "" && name == filepath.Base(name) && !strings.HasPrefix(name, ".")
+}
+
+// checkMediaFiles rejects items whose imageFile or videoFile isn't a plain
+// file name, since those are later joined to the project's media dirs.
+func checkMediaFiles(kind string, items []map[string]any) error {
+	for i, item := range items {
+		for _, key := range []string{"imageFile", "videoFile"} {
+			if name, ok := item[key].(string); ok && name != "" && !isBaseName(name) {
+				return fmt.Errorf("%s %d: %s %q must be a plain file name", kind, i+1, key, name)
+			}
 		}
 	}
+	return nil
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"results":  results,
-		"provider": req.Provider,
+// resolveSymlinks evaluates symlinks in the longest existing prefix of path
+// and appends the remaining, not-yet-created components unchanged.
+func resolveSymlinks(path string) (string, error) {
+	var missing []string
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				real = filepath.Join(real, missing[i])
+			}
+			return real, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(path))
+		path = parent
+	}
+}
+
+// copyDir recursively copies src to dst, preserving file modes.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		return copyFile(path, target, info.Mode().Perm())
 	})
 }
 
-func generateCharacterImage(prompt, provider, color string) string {
-	// TODO: Integrate actual image generation APIs
-	// For now, return placeholder
-	// 
-	// Provider integration points:
-	// - nanobananopro: Call Nano Banana Pro API
-	// - midjourney: Call Midjourney API (via Discord or third-party)
-	// - dalle: Call OpenAI DALL-E 3 API
-	// - stability: Call Stability AI API
-	// - leonardo: Call Leonardo AI API
-	
-	// Placeholder with character number extracted from context
-	return fmt.Sprintf("https://placehold.co/512x512/%s/ffffff?text=Character+Art", color)
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
 }
 
-// Save individual keyframe image
-type SaveKeyframeRequest struct {
-	ProjectPath string `json:"projectPath"`
-	SceneIndex  int    `json:"sceneIndex"`
-	ImageData   string `json:"imageData"` // base64 data URL
+// writeFileAtomic streams r into path through a temp file in the same
+// directory, renaming it into place only once everything is written, so a
+// failed or partial write never appears at path. It returns the bytes
+// written.
+func writeFileAtomic(path string, r io.Reader, perm os.FileMode) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	n, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
-func (s *Server) HandleSaveKeyframe(w http.ResponseWriter, r *http.Request) {
-	var req SaveKeyframeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+// HandleMoveProject renames or relocates a project folder within ProjectsRoot.
+func (s *Server) HandleMoveProject(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	var req struct {
+		Path string `json:"path"`
+	}
+	if !decodeJSON(w, r, &req, maxJSONBody) {
 		return
 	}
 
-	if req.ProjectPath == "" {
-		http.Error(w, "Project path is required", http.StatusBadRequest)
+	srcPath, err := s.projectDir(projectID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
 		return
 	}
-
-	if req.ImageData == "" {
-		http.Error(w, "Image data is required", http.StatusBadRequest)
+	dstPath, err := resolveProjectPath(s.ProjectsRoot, req.Path)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid destination path: "+err.Error())
 		return
 	}
-
-	// Create keyframes directory
-	keyframesDir := filepath.Join(req.ProjectPath, "keyframes")
-	if err := os.MkdirAll(keyframesDir, 0755); err != nil {
-		http.Error(w, "Failed to create keyframes directory: "+err.Error(), http.StatusInternalServerError)
+	if realSrc, err := resolveSymlinks(srcPath); err == nil && realSrc == dstPath {
+		writeJSONError(w, http.StatusBadRequest, "Destination is the current project path")
+		return
+	}
+	if _, err := os.Lstat(dstPath); err == nil {
+		writeJSONError(w, http.StatusConflict, "Destination already exists")
 		return
 	}
 
-	// Save the image
-	filename := fmt.Sprintf("scene_%d.png", req.SceneIndex)
-	imagePath := filepath.Join(keyframesDir, filename)
-
-	if strings.HasPrefix(req.ImageData, "data:image") {
-		if err := saveBase64Image(req.ImageData, imagePath); err != nil {
-			http.Error(w, "Failed to save image: "+err.Error(), http.StatusInternalServerError)
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create destination parent: "+err.Error())
+		return
+	}
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		if !errors.Is(err, syscall.EXDEV) {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to move project: "+err.Error())
 			return
 		}
-	} else {
-		http.Error(w, "Invalid image data format (expected base64 data URL)", http.StatusBadRequest)
-		return
+		// Rename can't cross filesystems; copy then delete the original
+		if err := copyDir(srcPath, dstPath); err != nil {
+			os.RemoveAll(dstPath)
+			writeJSONError(w, http.StatusInternalServerError, "Failed to copy project: "+err.Error())
+			return
+		}
+		if err := os.RemoveAll(srcPath); err != nil {
+			slog.Warn("failed to remove moved project source", "path", srcPath, "error", err)
+		}
 	}
 
-	slog.Info("saved keyframe", "path", imagePath, "scene", req.SceneIndex)
+	s.mu.Lock()
+	if project, exists := s.projects[projectID]; exists {
+		project.Path = dstPath
+	}
+	s.mu.Unlock()
+
+	slog.Info("moved project", "project", projectID, "from", srcPath, "to", dstPath)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{
 		"success":   true,
-		"filename":  filename,
-		"path":      imagePath,
-		"sceneIndex": req.SceneIndex,
+		"projectId": projectID,
+		"path":      dstPath,
 	})
 }
 
-// Save video clips to project
-type SaveVideoClipsRequest struct {
-	ProjectPath string                 `json:"projectPath"`
-	Storyboard  map[string]interface{} `json:"storyboard"`
-	VideoClips  []interface{}          `json:"videoClips"`
-}
-
-func (s *Server) HandleSaveVideoClips(w http.ResponseWriter, r *http.Request) {
-	var req SaveVideoClipsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
-		return
+// cloneProject deep-copies a project under a new ID. Scenes get fresh IDs,
+// and style versions are rekeyed to match, so edits to one project can never
+// address the other's scenes. The copy has no explicit Path.
+func cloneProject(project *Project, id string) (*Project, error) {
+	data, err := json.Marshal(project)
+	if err != nil {
+		return nil, err
 	}
-
-	if req.ProjectPath == "" {
-		http.Error(w, "Project path is required", http.StatusBadRequest)
-		return
+	clone := &Project{}
+	if err := json.Unmarshal(data, clone); err != nil {
+		return nil, err
+	}
+	clone.ID = id
+	clone.Path = ""
+	clone.CreatedAt = time.Now()
+
+	sceneIDs := make(map[string]string, len(clone.Scenes))
+	for i := range clone.Scenes {
+		newID := randomID("scene_")
+		sceneIDs[clone.Scenes[i].ID] = newID
+		clone.Scenes[i].ID = newID
+	}
+	for i, version := range clone.StyleVersions {
+		images := make(map[string]string, len(version.SceneImages))
+		for sceneID, imageURL := range version.SceneImages {
+			if newID, ok := sceneIDs[sceneID]; ok {
+				images[newID] = imageURL
+			}
+		}
+		clone.StyleVersions[i].SceneImages = images
 	}
+	return clone, nil
+}
 
-	// Create project directory
-	if err := os.MkdirAll(req.ProjectPath, 0755); err != nil {
-		http.Error(w, "Failed to create project directory: "+err.Error(), http.StatusInternalServerError)
+// HandleDuplicateProject forks a project under a new ID so creators can try
+// variations. With ?copyFiles=true the project folder, images and videos
+// included, is copied to a new folder under ProjectsRoot too (not in safe
+// mode).
+func (s *Server) HandleDuplicateProject(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	copyFiles := r.URL.Query().Get("copyFiles") == "true"
+	if copyFiles && s.SafeMode {
+		writeJSONError(w, http.StatusForbidden, "Copying project files is disabled in safe mode")
 		return
 	}
 
-	// Create videos directory
-	videosDir := filepath.Join(req.ProjectPath, "videos")
-	if err := os.MkdirAll(videosDir, 0755); err != nil {
-		http.Error(w, "Failed to create videos directory: "+err.Error(), http.StatusInternalServerError)
+	s.mu.Lock()
+	project, exists := s.projects[projectID]
+	if !exists {
+		s.mu.Unlock()
+		writeJSONError(w, http.StatusNotFound, "Project not found")
 		return
 	}
-
-	// Save video clips JSON for the editor
-	clipsData := map[string]interface{}{
-		"storyboard":  req.Storyboard,
-		"videoClips":  req.VideoClips,
-		"generatedAt": time.Now().Format(time.RFC3339),
+	// Registered under the same lock as the ID is picked, like a create
+	newID := s.newProjectID()
+	clone, err := cloneProject(project, newID)
+	if err == nil {
+		s.projects[newID] = clone
 	}
-
-	clipsJSON, err := json.MarshalIndent(clipsData, "", "  ")
+	s.mu.Unlock()
 	if err != nil {
-		http.Error(w, "Failed to serialize clips data: "+err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to copy project: "+err.Error())
 		return
 	}
 
-	clipsPath := filepath.Join(req.ProjectPath, "video-clips.json")
-	if err := os.WriteFile(clipsPath, clipsJSON, 0644); err != nil {
-		http.Error(w, "Failed to save clips file: "+err.Error(), http.StatusInternalServerError)
-		return
+	dstPath := filepath.Join(s.ProjectsRoot, newID)
+	copiedFiles := 0
+	if copyFiles {
+		srcPath, err := s.projectDir(projectID)
+		if err == nil {
+			lock := s.projectLock(srcPath)
+			lock.RLock()
+			err = copyDir(srcPath, dstPath)
+			lock.RUnlock()
+			if err == nil {
+				copiedFiles, err = countFiles(dstPath)
+			}
+		}
+		if err != nil {
+			s.mu.Lock()
+			delete(s.projects, newID)
+			s.mu.Unlock()
+			os.RemoveAll(dstPath)
+			if os.IsNotExist(err) {
+				writeJSONError(w, http.StatusConflict, "Project has no folder to copy")
+				return
+			}
+			writeJSONError(w, http.StatusInternalServerError, "Failed to copy project files: "+err.Error())
+			return
+		}
 	}
 
-	slog.Info("saved video clips", "path", clipsPath, "clips", len(req.VideoClips))
+	slog.Info("duplicated project", "project", projectID, "copy", newID, "files", copiedFiles)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{
-		"success":   true,
-		"path":      clipsPath,
+		"success":     true,
+		"projectId":   newID,
+		"path":        dstPath,
+		"copiedFiles": copiedFiles,
+		"redirect":    "/storyboard/" + newID,
+	})
+}
+
+// HandleDeleteProject drops a project from the store and, with
+// ?deleteFiles=true, removes its folder under ProjectsRoot (not in safe mode).
+// Deleting a project that is already gone succeeds, so retries are safe.
+func (s *Server) HandleDeleteProject(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	deleteFiles := r.URL.Query().Get("deleteFiles") == "true"
+	if deleteFiles && s.SafeMode {
+		writeJSONError(w, http.StatusForbidden, "Deleting project files is disabled in safe mode")
+		return
+	}
+
+	s.mu.RLock()
+	project, exists := s.projects[projectID]
+	s.mu.RUnlock()
+
+	removedFiles := 0
+	var projectPath string
+	if deleteFiles {
+		dir := filepath.Join(s.ProjectsRoot, projectID)
+		if exists && project.Path != "" {
+			dir = project.Path
+		} else if projectID != filepath.Base(projectID) || strings.HasPrefix(projectID, ".") {
+			writeJSONError(w, http.StatusBadRequest, "Invalid project id")
+			return
+		}
+
+		var err error
+		projectPath, err = resolveProjectPath(s.ProjectsRoot, dir)
+		if err == nil {
+			if root, rootErr := resolveProjectPath(s.ProjectsRoot, "."); rootErr == nil && projectPath == root {
+				err = errPathOutsideRoot
+			}
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid project path: "+err.Error())
+			return
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.projects, projectID)
+	s.mu.Unlock()
+
+	if deleteFiles {
+		lock := s.projectLock(projectPath)
+		lock.Lock()
+		var err error
+		removedFiles, err = removeProjectDir(projectPath)
+		lock.Unlock()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to delete project files: "+err.Error())
+			return
+		}
+	}
+	s.clearGenerations(r.Context(), []string{projectID, projectPath})
+
+	slog.Info("deleted project", "project", projectID, "files", removedFiles)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":      true,
+		"projectId":    projectID,
+		"deleted":      exists || removedFiles > 0,
+		"removedFiles": removedFiles,
+	})
+}
+
+// removeProjectDir deletes a project folder and returns how many files it
+// held. A missing folder removes nothing.
+func removeProjectDir(dir string) (int, error) {
+	count, err := countFiles(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return count, os.RemoveAll(dir)
+}
+
+// countFiles counts the files under dir.
+func countFiles(dir string) (int, error) {
+	count := 0
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// HandleProjectKeyframe serves a keyframe image from disk so the storyboard can
+// point <img src> at a real, cacheable URL instead of inlined base64.
+func (s *Server) HandleProjectKeyframe(w http.ResponseWriter, r *http.Request) {
+	projectPath, err := s.projectDir(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	// The URL is always "<index>.png", with the scene's zero-based index; the
+	// keyframe may be saved in any image format and is served with its real
+	// content type
+	name := r.PathValue("file")
+	index, err := strconv.Atoi(strings.TrimSuffix(name, ".png"))
+	if err != nil || !strings.HasSuffix(name, ".png") || index < 0 {
+		writeJSONError(w, http.StatusNotFound, "Keyframe not found")
+		return
+	}
+
+	// Same file and lookup order as HandleLoadProject: scene_N counting from
+	// 1, in keyframes first, then images
+	stem := fmt.Sprintf("scene_%d", index+1)
+	keyframesDir := filepath.Join(projectPath, "keyframes")
+	filename := findImageFile(keyframesDir, stem)
+	imgPath := filepath.Join(keyframesDir, filename)
+	if _, err := os.Stat(imgPath); os.IsNotExist(err) {
+		imagesDir := filepath.Join(projectPath, "images")
+		filename = findImageFile(imagesDir, stem)
+		imgPath = filepath.Join(imagesDir, filename)
+	}
+
+	f, err := os.Open(imgPath)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Keyframe not found")
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read keyframe: "+err.Error())
+		return
+	}
+
+	// Keyframes are overwritten in place by save-keyframe, so let the browser
+	// cache them but revalidate against Last-Modified on each use.
+	w.Header().Set("Content-Type", detectMimeType(imgPath))
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, filename, info.ModTime(), f)
+}
+
+// HandleProjectVideo serves a scene's clip from the project folder with
+// http.ServeContent, so players can seek with Range requests without waiting
+// for the clip to be published to /static/. The scene is addressed as for
+// HandleGetScene, by ID or zero-based index; projects known only from disk
+// take the index alone.
+func (s *Server) HandleProjectVideo(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	projectPath, err := s.projectDir(projectID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	ref := r.PathValue("scene")
+	index, found := -1, false
+	s.mu.RLock()
+	if project, ok := s.projects[projectID]; ok {
+		index, found = sceneIndex(project, ref)
+	} else if i, err := strconv.Atoi(ref); err == nil && i >= 0 {
+		index, found = i, true
+	}
+	s.mu.RUnlock()
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Scene not found")
+		return
+	}
+
+	// Clips are renamed into place once fully written, so this never opens
+	// one mid-render. Scene files count from 1.
+	videosDir := filepath.Join(projectPath, "videos")
+	filename := findSceneVideo(videosDir, index+1)
+	f, err := os.Open(filepath.Join(videosDir, filename))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Scene video not found")
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read scene video: "+err.Error())
+		return
+	}
+
+	// Clips are regenerated in place, so revalidate on each use
+	w.Header().Set("Content-Type", videoMimeTypes[filepath.Ext(filename)])
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, filename, info.ModTime(), f)
+}
+
+// sceneIndex resolves a scene reference from a URL, either a scene ID or a
+// zero-based position, to its index in project.Scenes.
+func sceneIndex(project *Project, ref string) (int, bool) {
+	for i, scene := range project.Scenes {
+		if scene.ID == ref {
+			return i, true
+		}
+	}
+	if i, err := strconv.Atoi(ref); err == nil && i >= 0 && i < len(project.Scenes) {
+		return i, true
+	}
+	return 0, false
+}
+
+// HandleGetScene returns a single scene, addressed by ID or zero-based index.
+func (s *Server) HandleGetScene(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+
+	s.mu.RLock()
+	project, exists := s.projects[projectID]
+	var scene Scene
+	var voice string
+	found := false
+	if exists {
+		var i int
+		if i, found = sceneIndex(project, r.PathValue("scene")); found {
+			scene = project.Scenes[i]
+			voice = narrationVoice(project.Characters, scene)
+		}
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Scene not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Scene
+		Voice string `json:"voice"`
+	}{scene, voice})
+}
+
+// HandleUpdateScene applies a partial edit to one scene; fields missing from
+// the body are left as they are.
+func (s *Server) HandleUpdateScene(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Narration   *string `json:"narration"`
+		ImagePrompt *string `json:"imagePrompt"`
+		ImageURL    *string `json:"imageUrl"`
+		Duration    *int    `json:"duration"`
+	}
+	if !decodeJSON(w, r, &req, maxMediaJSONBody) {
+		return
+	}
+	if req.Duration != nil {
+		if err := validateSceneDuration(*req.Duration); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	var imageSize *mediaSize
+	if req.ImageURL != nil {
+		imageSize = s.sceneImageSize(*req.ImageURL)
+	}
+
+	s.mu.Lock()
+	project, exists := s.projects[r.PathValue("id")]
+	if !exists {
+		s.mu.Unlock()
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	i, found := sceneIndex(project, r.PathValue("scene"))
+	if !found {
+		s.mu.Unlock()
+		writeJSONError(w, http.StatusNotFound, "Scene not found")
+		return
+	}
+	scene := &project.Scenes[i]
+	if req.Narration != nil {
+		scene.Narration = *req.Narration
+	}
+	if req.ImagePrompt != nil {
+		scene.ImagePrompt = *req.ImagePrompt
+	}
+	if req.ImageURL != nil {
+		scene.ImageURL = *req.ImageURL
+		scene.ImageSize = imageSize
+		scene.Status = sceneStatusFor(SceneDraft, scene.ImageURL != "", scene.VideoURL != "")
+	}
+	if req.Duration != nil {
+		scene.Duration = *req.Duration
+	}
+	updated := *scene
+	s.mu.Unlock()
+	s.broadcast(r.PathValue("id"), ProjectEvent{Type: "scene", SceneID: updated.ID, Scene: &updated})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// HandleRegenerateScene re-renders one scene's keyframe from its current
+// ImagePrompt, rebuilding the character section so references match the
+// project's current characters. The body may override the project's provider.
+func (s *Server) HandleRegenerateScene(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Provider string `json:"provider"`
+	}
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req, maxJSONBody) {
+		return
+	}
+	if req.Provider != "" {
+		if _, err := s.imageProvider(req.Provider); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	projectID := r.PathValue("id")
+	s.mu.RLock()
+	project, exists := s.projects[projectID]
+	var sceneID, prompt, provider, style string
+	var sceneNum int
+	var refs []CharacterRef
+	found := false
+	if exists {
+		var i int
+		if i, found = sceneIndex(project, r.PathValue("scene")); found {
+			scene := project.Scenes[i]
+			sceneID, sceneNum = scene.ID, i+1
+			refs = characterReferences(project.Characters, project.ArtImages, scene.CharacterWeights)
+			prompt = buildScenePrompt(sceneDescription(scene.ImagePrompt), project.Characters, project.ArtImages, refs)
+			provider, style = project.ImageProvider, project.Style
+		}
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Scene not found")
+		return
+	}
+	if req.Provider != "" {
+		provider = req.Provider
+	}
+
+	// Generate outside the project lock so regenerations of other scenes
+	// run in parallel; setSceneImage only touches this scene
+	release := s.acquireProvider(provider)
+	imageURL := s.generateSceneImage(r.Context(), styledPrompt(prompt, style), provider, sceneNum, GenOptions{References: refs})
+	release()
+	s.recordGeneration(projectID, generatedSceneImage, sceneNum-1, imageURL, provider)
+
+	if !s.setSceneImage(projectID, sceneID, imageURL) {
+		writeJSONError(w, http.StatusNotFound, "Scene not found")
+		return
+	}
+	slog.Info("regenerated scene", "project", projectID, "scene", sceneID, "provider", provider)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"sceneId":  sceneID,
+		"imageUrl": imageURL,
+		"prompt":   prompt,
+		"provider": provider,
+	})
+}
+
+// sceneDescription strips the character section buildScenePrompt appends,
+// leaving the scene's own description.
+func sceneDescription(imagePrompt string) string {
+	for _, marker := range []string{"\n\nCharacters in scene", "\n\n["} {
+		if i := strings.Index(imagePrompt, marker); i >= 0 {
+			imagePrompt = imagePrompt[:i]
+		}
+	}
+	return imagePrompt
+}
+
+// defaultRefineStrength keeps most of the current keyframe's composition.
+const defaultRefineStrength = 0.35
+
+// currentSceneImage returns the bytes of a scene's current keyframe: the
+// saved file in the project folder if there is one, else its image URL.
+func (s *Server) currentSceneImage(ctx context.Context, projectID string, sceneNum int, imageURL string) ([]byte, error) {
+	if projectPath, err := s.projectDir(projectID); err == nil {
+		keyframesDir := filepath.Join(projectPath, "keyframes")
+		if data, err := os.ReadFile(filepath.Join(keyframesDir, findImageFile(keyframesDir, fmt.Sprintf("scene_%d", sceneNum)))); err == nil {
+			return data, nil
+		}
+	}
+
+	switch {
+	case imageURL == "":
+		return nil, errors.New("scene has no image to refine")
+	case strings.HasPrefix(imageURL, "data:"):
+		_, data, err := decodeDataURL(imageURL)
+		return data, err
+	case strings.HasPrefix(imageURL, "/static/"):
+		imgPath, _ := s.staticFilePath(imageURL)
+		return os.ReadFile(imgPath)
+	default:
+		resp, err := s.httpGet(ctx, imageURL)
+		if err != nil {
+			return nil, fmt.Errorf("fetch current image: %w", err)
+		}
+		defer resp.Body.Close()
+		return io.ReadAll(io.LimitReader(resp.Body, 50<<20))
+	}
+}
+
+// HandleRefineScene nudges a scene's keyframe rather than re-rolling it: the
+// current image is sent to the provider as an img2img init image with a low
+// denoising strength and a tweak prompt, so the variation keeps composition.
+func (s *Server) HandleRefineScene(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Prompt   string  `json:"prompt"`
+		Strength float64 `json:"strength"`
+	}
+	if !decodeJSON(w, r, &req, maxJSONBody) {
+		return
+	}
+	if req.Strength == 0 {
+		req.Strength = defaultRefineStrength
+	}
+	if req.Strength < 0 || req.Strength > 1 {
+		writeJSONError(w, http.StatusBadRequest, "strength must be between 0 and 1")
+		return
+	}
+
+	projectID := r.PathValue("id")
+	s.mu.RLock()
+	project, exists := s.projects[projectID]
+	var scene Scene
+	var sceneNum int
+	var provider, style string
+	var refs []CharacterRef
+	found := false
+	if exists {
+		var i int
+		if i, found = sceneIndex(project, r.PathValue("scene")); found {
+			scene = project.Scenes[i]
+			sceneNum = i + 1
+			provider, style = project.ImageProvider, project.Style
+			refs = characterReferences(project.Characters, project.ArtImages, scene.CharacterWeights)
+		}
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "Scene not found")
+		return
+	}
+
+	initImage, err := s.currentSceneImage(r.Context(), projectID, sceneNum, scene.ImageURL)
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, "Failed to read current image: "+err.Error())
+		return
+	}
+
+	prompt := scene.ImagePrompt
+	if req.Prompt != "" {
+		prompt += "\n\nRefinement: " + req.Prompt
+	}
+
+	release := s.acquireProvider(provider)
+	imageURL := s.generateSceneImage(r.Context(), styledPrompt(prompt, style), provider, sceneNum, GenOptions{
+		References: refs,
+		InitImage:  initImage,
+		Strength:   req.Strength,
+	})
+	release()
+	s.recordGeneration(projectID, generatedSceneImage, sceneNum-1, imageURL, provider)
+
+	if !s.setSceneImage(projectID, scene.ID, imageURL) {
+		writeJSONError(w, http.StatusNotFound, "Scene not found")
+		return
+	}
+	slog.Info("refined scene", "project", projectID, "scene", scene.ID, "strength", req.Strength)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"sceneId":  scene.ID,
+		"imageUrl": imageURL,
+		"strength": req.Strength,
+	})
+}
+
+type ArtImagesRequest struct {
+	Characters []struct {
+		Index       int    `json:"index"`
+		Description string `json:"description"`
+	} `json:"characters"`
+	Provider  string `json:"provider"`
+	// ProjectID, when set, records each result so it survives a crash
+	ProjectID string `json:"projectId"`
+}
+
+type ArtImagesResult struct {
+	Index    int            `json:"index"`
+	ImageURL string         `json:"imageUrl,omitempty"`
+	Prompt   string         `json:"prompt"`
+	Error    *ProviderError `json:"error,omitempty"`
+}
+
+// ArtImagesError reports a character whose art couldn't be generated.
+type ArtImagesError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+func (s *Server) HandleGenerateArtImages(w http.ResponseWriter, r *http.Request) {
+	var req ArtImagesRequest
+	if !decodeJSON(w, r, &req, maxJSONBody) {
+		return
+	}
+	if _, err := s.imageProvider(req.Provider); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Characters are generated in parallel up to the provider's limit; each
+	// result lands at its character's position, so the order matches the
+	// request. A failure only affects its own character.
+	results := make([]ArtImagesResult, len(req.Characters))
+	failures := make([]error, len(req.Characters))
+	var g errgroup.Group
+	g.SetLimit(s.providerLimit(req.Provider))
+	for i, char := range req.Characters {
+		g.Go(func() error {
+			release := s.acquireProvider(req.Provider)
+			imageURL, err := s.generateCharacterImage(r.Context(), char.Description, req.Provider, char.Index)
+			release()
+			results[i] = ArtImagesResult{
+				Index:  char.Index,
+				Prompt: char.Description,
+			}
+			if err != nil {
+				failures[i] = err
+				errors.As(err, &results[i].Error)
+				return nil
+			}
+			s.recordGeneration(req.ProjectID, generatedCharacter, char.Index, imageURL, req.Provider)
+			results[i].ImageURL = imageURL
+			return nil
+		})
+	}
+	g.Wait()
+
+	errs := []ArtImagesError{}
+	for i, err := range failures {
+		if err != nil {
+			errs = append(errs, ArtImagesError{Index: results[i].Index, Error: err.Error()})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"results":  results,
+		"errors":   errs,
+		"provider": req.Provider,
+	})
+}
+
+// generateImage renders prompt with the named provider. When the provider's
+// call fails for any other reason than a *ProviderError (e.g. a missing API
+// key), it falls back to placeholder art so the UI still renders. A
+// *ProviderError, such as a safety rejection, is returned instead so the
+// user can change the prompt.
+func (s *Server) generateImage(ctx context.Context, provider, prompt string, opts GenOptions) (string, error) {
+	p, err := s.imageProvider(provider)
+	if err != nil {
+		return "", err
+	}
+	imageURL, err := p.Generate(ctx, prompt, opts)
+	if err == nil {
+		return imageURL, nil
+	}
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return "", providerErr
+	}
+	slog.Warn("image provider failed, using placeholder", "provider", p.Name(), "error", err)
+	return placeholderImage(opts), nil
+}
+
+// generateCharacterImage renders character art with the named provider.
+func (s *Server) generateCharacterImage(ctx context.Context, prompt, provider string, index int) (string, error) {
+	return s.generateImage(ctx, provider, prompt, GenOptions{Character: index})
+}
+
+// CharacterRef is a character reference passed to the image provider, with a
+// normalized weight controlling how strongly it influences the scene.
+type CharacterRef struct {
+	Index    int     `json:"index"`
+	ImageURL string  `json:"imageUrl"`
+	Weight   float64 `json:"weight"`
+}
+
+// characterReferences pairs each character with its art and a weight
+// normalized to sum to 1. Characters without an explicit weight count as 1,
+// so an empty map yields equal weights; negative weights are treated as 0.
+func characterReferences(characters []Character, artImages []ArtImages, weights map[int]float64) []CharacterRef {
+	artMap := make(map[int]string)
+	for _, art := range artImages {
+		artMap[art.Index] = art.ImageURL
+	}
+
+	refs := make([]CharacterRef, len(characters))
+	total := 0.0
+	for i, char := range characters {
+		weight := 1.0
+		if w, ok := weights[char.Index]; ok {
+			weight = max(w, 0)
+		}
+		refs[i] = CharacterRef{Index: char.Index, ImageURL: artMap[char.Index], Weight: weight}
+		total += weight
+	}
+	for i := range refs {
+		if total > 0 {
+			refs[i].Weight /= total
+		} else {
+			refs[i].Weight = 1 / float64(len(refs))
+		}
+	}
+	return refs
+}
+
+// generateSceneImage renders a scene keyframe through the image provider,
+// passing the weighted character references for consistency and, for
+// img2img refinement, the current keyframe as the init image. Scenes always
+// get an image: any failure falls back to placeholder art.
+func (s *Server) generateSceneImage(ctx context.Context, prompt, provider string, sceneNum int, opts GenOptions) string {
+	opts.Scene = sceneNum
+	imageURL, err := s.generateImage(ctx, provider, prompt, opts)
+	if err != nil {
+		slog.Warn("scene image failed, using placeholder", "provider", provider, "scene", sceneNum, "error", err)
+		return placeholderImage(opts)
+	}
+	return imageURL
+}
+
+// acquireProvider blocks until a request slot for the provider is free and
+// returns a func that releases it.
+func (s *Server) acquireProvider(provider string) func() {
+	s.providerMu.Lock()
+	slots, ok := s.providerSlots[provider]
+	if !ok {
+		slots = make(chan struct{}, s.providerLimit(provider))
+		s.providerSlots[provider] = slots
+	}
+	s.providerMu.Unlock()
+
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// setSceneImage updates a scene's image URL by scene ID. It reports false if
+// the project or scene no longer exists.
+func (s *Server) setSceneImage(projectID, sceneID, imageURL string) bool {
+	// Read before locking; a local image is only its header, but still I/O
+	size := s.sceneImageSize(imageURL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	project, exists := s.projects[projectID]
+	if !exists {
+		return false
+	}
+	for i := range project.Scenes {
+		if project.Scenes[i].ID == sceneID {
+			s.applySceneImage(projectID, &project.Scenes[i], imageURL, size)
+			return true
+		}
+	}
+	return false
+}
+
+// applySceneImage sets a scene's image and its size, updates its status to
+// match, and tells the project's clients. The caller must hold s.mu.
+func (s *Server) applySceneImage(projectID string, scene *Scene, imageURL string, size *mediaSize) {
+	scene.ImageURL = imageURL
+	scene.ImageSize = size
+	if scene.Status != SceneVideoReady {
+		scene.Status = sceneStatusFor(SceneDraft, imageURL != "", false)
+	}
+	s.broadcast(projectID, ProjectEvent{Type: "scene-image", SceneID: scene.ID, ImageURL: imageURL})
+}
+
+// scenePosition returns a clip request's zero-based scene position, looked up
+// by scene ID in the in-memory project when possible.
+func (s *Server) scenePosition(projectID string, scene SceneInput) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if project, ok := s.projects[projectID]; ok && scene.ID != "" {
+		if i, found := sceneIndex(project, scene.ID); found {
+			return i
+		}
+	}
+	return scene.Index
+}
+
+// sceneDuration returns a clip request's duration, falling back to the one
+// stored on the in-memory project's scene.
+func (s *Server) sceneDuration(projectID string, scene SceneInput) int {
+	if scene.Duration != 0 {
+		return scene.Duration
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if project, ok := s.projects[projectID]; ok && scene.ID != "" {
+		if i, found := sceneIndex(project, scene.ID); found {
+			return project.Scenes[i].Duration
+		}
+	}
+	return 0
+}
+
+// setSceneVideo records a generated video for a scene by scene ID, marking
+// the scene failed if generation produced nothing.
+func (s *Server) setSceneVideo(projectID, sceneID, videoURL string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	project, exists := s.projects[projectID]
+	if !exists {
+		return false
+	}
+	for i := range project.Scenes {
+		if project.Scenes[i].ID == sceneID {
+			project.Scenes[i].VideoURL = videoURL
+			if videoURL == "" {
+				project.Scenes[i].Status = SceneFailed
+			} else {
+				project.Scenes[i].Status = SceneVideoReady
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// setArtImage updates (or adds) the character art URL for a character index.
+func (s *Server) setArtImage(projectID string, index int, imageURL string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	project, exists := s.projects[projectID]
+	if !exists {
+		return false
+	}
+	for i := range project.ArtImages {
+		if project.ArtImages[i].Index == index {
+			project.ArtImages[i].ImageURL = imageURL
+			return true
+		}
+	}
+	project.ArtImages = append(project.ArtImages, ArtImages{Index: index, ImageURL: imageURL})
+	return true
+}
+
+// sceneImageTasks builds a regeneration task for every unlocked scene. The
+// caller must hold s.mu.
+func (s *Server) sceneImageTasks(project *Project, style string) ([]JobItem, []jobTask) {
+	var items []JobItem
+	var tasks []jobTask
+	for i, scene := range project.Scenes {
+		if scene.Locked {
+			continue
+		}
+		projectID, sceneID, sceneNum := project.ID, scene.ID, i+1
+		prompt := styledPrompt(scene.ImagePrompt, style)
+		refs := characterReferences(project.Characters, project.ArtImages, scene.CharacterWeights)
+		provider := project.ImageProvider
+
+		items = append(items, JobItem{Kind: "scene", Index: i})
+		tasks = append(tasks, func() (string, error) {
+			imageURL := s.generateSceneImage(s.jobsCtx, prompt, provider, sceneNum, GenOptions{References: refs})
+			s.recordGeneration(projectID, generatedSceneImage, sceneNum-1, imageURL, provider)
+			if !s.setSceneImage(projectID, sceneID, imageURL) {
+				return "", errors.New("scene no longer exists")
+			}
+			return imageURL, nil
+		})
+	}
+	return items, tasks
+}
+
+// styledPrompt appends the project's global style to an image prompt.
+func styledPrompt(prompt, style string) string {
+	if style == "" {
+		return prompt
+	}
+	return prompt + "\n\nStyle: " + style
+}
+
+// HandleRegenerateAllScenes starts a background job that regenerates the image
+// for every unlocked scene in the project, in its current style. Poll /api/jobs/{jobId} for progress.
+func (s *Server) HandleRegenerateAllScenes(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+
+	s.mu.RLock()
+	project, exists := s.projects[projectID]
+	var provider string
+	var items []JobItem
+	var tasks []jobTask
+	if exists {
+		provider = project.ImageProvider
+		items, tasks = s.sceneImageTasks(project, project.Style)
+	}
+	s.mu.RUnlock()
+
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	job := s.newJob("regenerate-all", projectID, items)
+	go s.runJob(job.ID, provider, tasks)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{
+		"jobId":     job.ID,
+		"total":     job.Total,
+		"statusUrl": "/api/jobs/" + job.ID,
+	})
+}
+
+// StyleVersion is a snapshot of a project's images taken before a restyle,
+// so the previous look can be restored.
+type StyleVersion struct {
+	Version     int               `json:"version"`
+	Style       string            `json:"style"`
+	SceneImages map[string]string `json:"sceneImages"` // scene ID -> image URL
+	ArtImages   []ArtImages       `json:"artImages"`
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+// HandleRestyleProject sets a new global style and, as a background job,
+// regenerates every unlocked scene and all character art with it. The
+// previous images are kept as a StyleVersion for HandleRevertStyle.
+func (s *Server) HandleRestyleProject(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	var req struct {
+		Style string `json:"style"`
+	}
+	if !decodeJSON(w, r, &req, maxJSONBody) {
+		return
+	}
+	req.Style = strings.TrimSpace(req.Style)
+	if req.Style == "" {
+		writeJSONError(w, http.StatusBadRequest, "Style is required")
+		return
+	}
+
+	s.mu.Lock()
+	project, exists := s.projects[projectID]
+	if !exists {
+		s.mu.Unlock()
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+
+	backup := StyleVersion{
+		Version:     len(project.StyleVersions) + 1,
+		Style:       project.Style,
+		SceneImages: make(map[string]string, len(project.Scenes)),
+		ArtImages:   append([]ArtImages(nil), project.ArtImages...),
+		CreatedAt:   time.Now(),
+	}
+	for _, scene := range project.Scenes {
+		backup.SceneImages[scene.ID] = scene.ImageURL
+	}
+	project.StyleVersions = append(project.StyleVersions, backup)
+	project.Style = req.Style
+
+	provider := project.ImageProvider
+	items, tasks := s.sceneImageTasks(project, req.Style)
+	for _, char := range project.Characters {
+		index := char.Index
+		prompt := styledPrompt(char.Description, req.Style)
+		items = append(items, JobItem{Kind: "character", Index: index})
+		tasks = append(tasks, func() (string, error) {
+			imageURL, err := s.generateCharacterImage(s.jobsCtx, prompt, provider, index)
+			if err != nil {
+				return "", err
+			}
+			if !s.setArtImage(projectID, index, imageURL) {
+				return "", errors.New("project no longer exists")
+			}
+			return imageURL, nil
+		})
+	}
+	s.mu.Unlock()
+
+	job := s.newJob("restyle", projectID, items)
+	go s.runJob(job.ID, provider, tasks)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{
+		"jobId":         job.ID,
+		"total":         job.Total,
+		"statusUrl":     "/api/jobs/" + job.ID,
+		"backupVersion": backup.Version,
+	})
+}
+
+// HandleRevertStyle restores the images and style from a StyleVersion; the
+// latest one when no version is given. Like a restyle's regenerations, each
+// restored scene image updates the scene's status and is broadcast.
+func (s *Server) HandleRevertStyle(w http.ResponseWriter, r *http.Request) {
+	projectID := r.PathValue("id")
+	var req struct {
+		Version int `json:"version"`
+	}
+	if r.ContentLength != 0 {
+		if !decodeJSON(w, r, &req, maxJSONBody) {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	project, exists := s.projects[projectID]
+	if !exists {
+		writeJSONError(w, http.StatusNotFound, "Project not found")
+		return
+	}
+	if len(project.StyleVersions) == 0 {
+		writeJSONError(w, http.StatusNotFound, "No style versions to revert to")
+		return
+	}
+	if req.Version == 0 {
+		req.Version = len(project.StyleVersions)
+	}
+	if req.Version < 1 || req.Version > len(project.StyleVersions) {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("Unknown style version %d", req.Version))
+		return
+	}
+
+	backup := project.StyleVersions[req.Version-1]
+	project.Style = backup.Style
+	project.ArtImages = append([]ArtImages(nil), backup.ArtImages...)
+	for i := range project.Scenes {
+		if imageURL, ok := backup.SceneImages[project.Scenes[i].ID]; ok && imageURL != project.Scenes[i].ImageURL {
+			s.applySceneImage(projectID, &project.Scenes[i], imageURL, s.sceneImageSize(imageURL))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+// Save individual keyframe image
+type SaveKeyframeRequest struct {
+	ProjectPath string `json:"projectPath"`
+	SceneIndex  int    `json:"sceneIndex"`
+	ImageData   string `json:"imageData"` // base64 data URL
+}
+
+func (s *Server) HandleSaveKeyframe(w http.ResponseWriter, r *http.Request) {
+	var req SaveKeyframeRequest
+	if !decodeJSON(w, r, &req, maxMediaJSONBody) {
+		return
+	}
+
+	if req.ProjectPath == "" {
+		writeJSONError(w, http.StatusBadRequest, "Project path is required")
+		return
+	}
+	projectPath, err := resolveProjectPath(s.ProjectsRoot, req.ProjectPath)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid project path: "+err.Error())
+		return
+	}
+	req.ProjectPath = projectPath
+
+	lock := s.projectLock(req.ProjectPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if req.ImageData == "" {
+		writeJSONError(w, http.StatusBadRequest, "Image data is required")
+		return
+	}
+
+	// Create keyframes directory
+	keyframesDir := filepath.Join(req.ProjectPath, "keyframes")
+	if err := os.MkdirAll(keyframesDir, 0755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create keyframes directory: "+err.Error())
+		return
+	}
+
+	// Save the image
+	filename := fmt.Sprintf("scene_%d.png", req.SceneIndex)
+	imagePath := filepath.Join(keyframesDir, filename)
+
+	if strings.HasPrefix(req.ImageData, "data:image") {
+		imagePath, err = saveBase64Image(req.ImageData, imagePath)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to save image: "+err.Error())
+			return
+		}
+		filename = filepath.Base(imagePath)
+	} else {
+		writeJSONError(w, http.StatusBadRequest, "Invalid image data format (expected base64 data URL)")
+		return
+	}
+
+	slog.Info("saved keyframe", "path", imagePath, "scene", req.SceneIndex)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":   true,
+		"filename":  filename,
+		"path":      imagePath,
+		"sceneIndex": req.SceneIndex,
+	})
+}
+
+// Save video clips to project
+type SaveVideoClipsRequest struct {
+	ProjectPath string                 `json:"projectPath"`
+	Storyboard  map[string]interface{} `json:"storyboard"`
+	VideoClips  []interface{}          `json:"videoClips"`
+}
+
+func (s *Server) HandleSaveVideoClips(w http.ResponseWriter, r *http.Request) {
+	var req SaveVideoClipsRequest
+	if !decodeJSON(w, r, &req, maxMediaJSONBody) {
+		return
+	}
+
+	if req.ProjectPath == "" {
+		writeJSONError(w, http.StatusBadRequest, "Project path is required")
+		return
+	}
+	projectPath, err := resolveProjectPath(s.ProjectsRoot, req.ProjectPath)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid project path: "+err.Error())
+		return
+	}
+	req.ProjectPath = projectPath
+
+	lock := s.projectLock(req.ProjectPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Create project directory
+	if err := os.MkdirAll(req.ProjectPath, 0755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create project directory: "+err.Error())
+		return
+	}
+
+	// Create videos directory
+	videosDir := filepath.Join(req.ProjectPath, "videos")
+	if err := os.MkdirAll(videosDir, 0755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create videos directory: "+err.Error())
+		return
+	}
+
+	// Save video clips JSON for the editor
+	clipsData := map[string]interface{}{
+		"storyboard":  req.Storyboard,
+		"videoClips":  req.VideoClips,
+		"generatedAt": time.Now().Format(time.RFC3339),
+	}
+
+	clipsJSON, err := json.MarshalIndent(clipsData, "", "  ")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to serialize clips data: "+err.Error())
+		return
+	}
+
+	clipsPath := filepath.Join(req.ProjectPath, "video-clips.json")
+	if _, err := writeFileAtomic(clipsPath, bytes.NewReader(clipsJSON), 0644); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save clips file: "+err.Error())
+		return
+	}
+
+	slog.Info("saved video clips", "path", clipsPath, "clips", len(req.VideoClips))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":   true,
+		"path":      clipsPath,
 		"clipCount": len(req.VideoClips),
 	})
 }
@@ -348,284 +2011,1002 @@ type SceneInput struct {
 	EndFrame   *string `json:"endFrame"`
 	Narration  string  `json:"narration"`
 	Prompt     string  `json:"prompt"`
+	// Duration is the clip length to ask for, in seconds; 0 uses the
+	// project scene's Duration, if any
+	Duration   int     `json:"duration"`
 }
 
 type VideoClip struct {
-	SceneIndex  int    `json:"sceneIndex"`
-	VideoURL    string `json:"videoUrl"`
-	PosterURL   string `json:"posterUrl"`
-	Duration    string `json:"duration"`
-	HasEndFrame bool   `json:"hasEndFrame"`
+	SceneIndex        int        `json:"sceneIndex"`
+	VideoURL          string     `json:"videoUrl"`
+	PosterURL         string     `json:"posterUrl"`
+	// Duration is the rendered clip's length in seconds, probed from the
+	// file once it's done; RequestedDuration is what was asked for
+	Duration          float64    `json:"duration,omitempty"`
+	RequestedDuration int        `json:"requestedDuration,omitempty"`
+	// Video is the rendered clip's probed size, frame rate and codec
+	Video             *VideoMeta `json:"video,omitempty"`
+	HasEndFrame       bool       `json:"hasEndFrame"`
+	Status            JobStatus  `json:"status,omitempty"`
+	Error             string     `json:"error,omitempty"`
 }
 
 // HandleUploadVideo uploads a video blob to the static videos directory and returns the URL
 func (s *Server) HandleUploadVideo(w http.ResponseWriter, r *http.Request) {
-	// Parse multipart form (max 500MB)
-	if err := r.ParseMultipartForm(500 << 20); err != nil {
-		http.Error(w, "Failed to parse form: "+err.Error(), http.StatusBadRequest)
+	if !parseUpload(w, r, s.MaxUploadSize) {
+		return
+	}
+
+	sceneIndex := r.FormValue("sceneIndex")
+	if sceneIndex == "" {
+		writeJSONError(w, http.StatusBadRequest, "sceneIndex is required")
+		return
+	}
+
+	file, header, err := r.FormFile("video")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to get video file: "+err.Error())
+		return
+	}
+	defer file.Close()
+
+	// The extension comes from the content, not the client's filename, so
+	// only real videos end up served as scene clips
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read video data: "+err.Error())
+		return
+	}
+	if n == 0 {
+		writeJSONError(w, http.StatusBadRequest, "Video file is empty")
+		return
+	}
+	ext, ok := sniffVideoExtension(head[:n])
+	if !ok {
+		writeJSONError(w, http.StatusUnsupportedMediaType, "Unsupported video type "+http.DetectContentType(head[:n])+"; upload an mp4, webm or mov file")
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read video data: "+err.Error())
+		return
+	}
+
+	// Create static videos directory if it doesn't exist
+	staticVideosDir := filepath.Join(s.StaticDir, "videos")
+	if err := os.MkdirAll(staticVideosDir, 0755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create videos directory: "+err.Error())
+		return
+	}
+
+	// Save with scene index as filename
+	filename := fmt.Sprintf("scene_%s%s", sceneIndex, ext)
+	filePath := filepath.Join(staticVideosDir, filename)
+
+	// Stream to disk rather than holding the upload in memory
+	size, err := writeFileAtomic(filePath, file, 0644)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save video file: "+err.Error())
+		return
+	}
+
+	// Return the static URL
+	staticURL := fmt.Sprintf("/static/videos/%s", filename)
+	slog.Info("uploaded video", "scene", sceneIndex, "source", header.Filename, "path", filePath, "url", staticURL, "size", size)
+
+	resp := map[string]any{
+		"success":  true,
+		"videoUrl": staticURL,
+		"filename": filename,
+		"size":     size,
+	}
+	// The real length, size and codec, for the editor and render checks;
+	// an upload ffprobe can't read is still kept
+	if meta, err := probeVideo(r.Context(), filePath); err == nil {
+		resp["duration"] = meta.Duration
+		resp["video"] = meta
+	} else {
+		slog.Warn("failed to probe uploaded video", "path", filePath, "error", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleGenerateVideoClips starts a job that animates each scene's start
+// frame (towards its end frame, if any) with Veo 3 and returns immediately;
+// poll /api/video-clips/status/{jobId} for the clips. Without a GEMINI_API_KEY
+// scenes get placeholder clips so the storyboard still works offline.
+func (s *Server) HandleGenerateVideoClips(w http.ResponseWriter, r *http.Request) {
+	var req VideoClipRequest
+	if !decodeJSON(w, r, &req, maxMediaJSONBody) {
+		return
+	}
+
+	veo := s.Veo
+	if veo == nil || veo.APIKey == "" {
+		slog.Warn("GEMINI_API_KEY not set, generating placeholder clips")
+		veo = nil
+	}
+
+	items := make([]JobItem, len(req.Scenes))
+	clips := make([]VideoClip, len(req.Scenes))
+	for i, scene := range req.Scenes {
+		if err := validateSceneDuration(scene.Duration); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Scene %d: %v", scene.Index, err))
+			return
+		}
+		req.Scenes[i].Duration = s.sceneDuration(req.ProjectID, scene)
+		clips[i] = VideoClip{
+			SceneIndex:        scene.Index,
+			PosterURL:         scene.StartFrame,
+			RequestedDuration: req.Scenes[i].Duration,
+			HasEndFrame:       scene.EndFrame != nil && *scene.EndFrame != "",
+		}
+		items[i] = JobItem{Kind: "clip", Index: scene.Index, Clip: &clips[i]}
+	}
+	job := s.newJob("video-clips", req.ProjectID, items)
+
+	tasks := make([]jobTask, len(req.Scenes))
+	for i, scene := range req.Scenes {
+		hasEndFrame := clips[i].HasEndFrame
+		position := s.scenePosition(req.ProjectID, scene)
+
+		tasks[i] = func() (string, error) {
+			videoURL := generatePlaceholderVideo(scene.Index)
+			if veo != nil {
+				var err error
+				videoURL, err = s.generateVeoClip(veo, scene, hasEndFrame)
+				if err != nil {
+					if req.ProjectID != "" && scene.ID != "" {
+						s.setSceneVideo(req.ProjectID, scene.ID, "")
+					}
+					return "", err
+				}
+				s.recordClipMeta(s.jobsCtx, job.ID, i, videoURL)
+			}
+			if req.ProjectID != "" && scene.ID != "" {
+				s.setSceneVideo(req.ProjectID, scene.ID, videoURL)
+			}
+			s.recordGeneration(req.ProjectID, generatedSceneVideo, position, videoURL, "veo")
+			return videoURL, nil
+		}
+	}
+	// Respond from a snapshot; the runner updates job as soon as it starts
+	queued, _ := s.getJob(job.ID)
+	go s.runJob(job.ID, "veo", tasks)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{
+		"jobId":     queued.ID,
+		"status":    queued.Status,
+		"completed": queued.Completed,
+		"total":     queued.Total,
+		"error":     queued.Error,
+		"statusUrl": "/api/video-clips/status/" + queued.ID,
+	})
+}
+
+// generateVeoClip renders one scene with Veo and saves the clip under
+// /static/videos, returning its URL.
+func (s *Server) generateVeoClip(veo *VeoClient, scene SceneInput, hasEndFrame bool) (string, error) {
+	ctx := s.jobsCtx
+	startFrame, err := s.readFrame(ctx, scene.StartFrame)
+	if err != nil {
+		return "", fmt.Errorf("start frame: %w", err)
+	}
+	var endFrame *VeoFrame
+	if hasEndFrame {
+		frame, err := s.readFrame(ctx, *scene.EndFrame)
+		if err != nil {
+			return "", fmt.Errorf("end frame: %w", err)
+		}
+		endFrame = &frame
+	}
+
+	prompt := scene.Prompt
+	if scene.Narration != "" {
+		prompt += "\n\nNarration for context: " + scene.Narration
+	}
+	data, err := veo.GenerateClip(ctx, prompt, startFrame, endFrame, scene.Duration)
+	if err != nil {
+		return "", err
+	}
+
+	videosDir := filepath.Join(s.StaticDir, "videos")
+	if err := os.MkdirAll(videosDir, 0755); err != nil {
+		return "", err
+	}
+	filename := randomID("veo_") + ".mp4"
+	if _, err := writeFileAtomic(filepath.Join(videosDir, filename), bytes.NewReader(data), 0644); err != nil {
+		return "", err
+	}
+	slog.Info("veo clip ready", "scene", scene.Index, "file", filename, "size", len(data))
+	return "/static/videos/" + filename, nil
+}
+
+// HandleVideoClipsStatus reports a video-clips job with each clip's status;
+// clips get their videoUrl as they finish.
+func (s *Server) HandleVideoClipsStatus(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.getJob(r.PathValue("jobId"))
+	if !ok || job.Kind != "video-clips" {
+		writeJSONError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	clips := make([]VideoClip, len(job.Items))
+	for i, item := range job.Items {
+		clips[i] = VideoClip{SceneIndex: item.Index}
+		if item.Clip != nil {
+			clips[i] = *item.Clip
+		}
+		clips[i].VideoURL = item.Result
+		if item.Video != nil {
+			clips[i].Duration = item.Video.Duration
+			clips[i].Video = item.Video
+		}
+		clips[i].Status = item.Status
+		clips[i].Error = item.Error
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"jobId":     job.ID,
+		"status":    job.Status,
+		"completed": job.Completed,
+		"total":     job.Total,
+		"error":     job.Error,
+		"clips":     clips,
+	})
+}
+
+func generatePlaceholderVideo(sceneIndex int) string {
+	return fmt.Sprintf("https://storage.googleapis.com/gtv-videos-bucket/sample/ForBiggerEscapes.mp4#scene=%d", sceneIndex)
+}
+
+// Actual video generation using FFmpeg
+type GenerateVideoRequest struct {
+	ProjectPath        string   `json:"projectPath"`
+	SceneIndex         int      `json:"sceneIndex"`
+	FirstFrameURL      string   `json:"firstFrameUrl"`
+	LastFrameURL       string   `json:"lastFrameUrl"`
+	// Frames, when set, lists every image to crossfade through in order,
+	// taking the place of FirstFrameURL and LastFrameURL; Duration is
+	// shared evenly between them
+	Frames             []string `json:"frames"`
+	Duration           int      `json:"duration"`
+	Prompt             string   `json:"prompt"`
+	// Intermediate renders the clip losslessly so later passes (audio mux,
+	// subtitles, concat) only encode to the delivery codec once; the copy
+	// published for preview is re-encoded. Opt-in because lossless files
+	// are many times larger.
+	Intermediate       bool     `json:"intermediate"`
+	// ExtraFilters appends filter steps (e.g. "eq=saturation=1.2,vignette")
+	// to the generated chain; only honored when AllowCustomFilters is set
+	ExtraFilters       string   `json:"extraFilters"`
+	// NarrationStart and NarrationPadding offset the narration within the
+	// clip (seconds); see narrationFilter
+	NarrationStart     float64  `json:"narrationStart"`
+	NarrationPadding   float64  `json:"narrationPadding"`
+	// AudioPath is a narration file under ProjectsRoot to mux into the clip;
+	// the clip is lengthened if the narration wouldn't fit
+	AudioPath          string   `json:"audioPath"`
+	// Resolution ("1080x1920") or AspectRatio ("9:16") picks the frame size
+	// from outputSizes; 1920x1080 when both are unset
+	Resolution         string   `json:"resolution"`
+	AspectRatio        string   `json:"aspectRatio"`
+	// Effect picks the Ken Burns move for single-image clips; the
+	// slow-zoom-in preset when unset
+	Effect             Effect   `json:"effect"`
+	// Transition is the xfade transition between consecutive frames (see
+	// xfadeTransitions) and TransitionDuration its length in seconds; a
+	// fade of up to 1s when unset
+	Transition         string   `json:"transition"`
+	TransitionDuration float64  `json:"transitionDuration"`
+	// Encoding picks the codec, CRF, preset and container of a delivery
+	// clip; libx264 in mp4 at ffmpeg's defaults when unset
+	Encoding           Encoding `json:"encoding"`
+	// DryRun (or ?dryRun=true) returns the ffmpeg command and filtergraph
+	// the clip would be rendered with instead of rendering it
+	DryRun             bool     `json:"dryRun"`
+}
+
+func (s *Server) HandleGenerateVideo(w http.ResponseWriter, r *http.Request) {
+	var req GenerateVideoRequest
+	if !decodeJSON(w, r, &req, maxMediaJSONBody) {
+		return
+	}
+
+	if len(req.Frames) == 0 {
+		// An end frame on its own is animated like a single image
+		for _, url := range []string{req.FirstFrameURL, req.LastFrameURL} {
+			if url != "" {
+				req.Frames = append(req.Frames, url)
+			}
+		}
+	}
+	if len(req.Frames) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "A first frame, last frame or frames list is required")
+		return
+	}
+	if len(req.Frames) > maxClipFrames {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d frames can be crossfaded, got %d", maxClipFrames, len(req.Frames)))
+		return
+	}
+	if slices.Contains(req.Frames, "") {
+		writeJSONError(w, http.StatusBadRequest, "Frame URLs must not be empty")
+		return
+	}
+
+	if err := validateSceneDuration(req.Duration); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Duration == 0 {
+		req.Duration = 5
+	}
+
+	if err := validateNarrationTiming(req.NarrationStart, req.NarrationPadding, float64(req.Duration)); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	size, err := outputSize(req.Resolution, req.AspectRatio)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Effect, err = req.Effect.resolve(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validateTransition(req.Transition, req.TransitionDuration, req.Duration); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Intermediate && !req.Encoding.isDefault() {
+		writeJSONError(w, http.StatusBadRequest, "Encoding settings don't apply to intermediate clips, which are always lossless h264")
+		return
+	}
+	if req.Encoding, err = req.Encoding.resolve(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.ExtraFilters != "" {
+		if !s.AllowCustomFilters {
+			writeJSONError(w, http.StatusForbidden, "Custom filters are disabled on this server")
+			return
+		}
+		if err := validateExtraFilters(req.ExtraFilters); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if req.ProjectPath != "" {
+		projectPath, err := resolveProjectPath(s.ProjectsRoot, req.ProjectPath)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid project path: "+err.Error())
+			return
+		}
+		req.ProjectPath = projectPath
+	}
+
+	if req.AudioPath != "" {
+		audioPath, err := resolveProjectPath(s.ProjectsRoot, req.AudioPath)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid audio path: "+err.Error())
+			return
+		}
+		if info, err := os.Stat(audioPath); err != nil || info.IsDir() {
+			writeJSONError(w, http.StatusBadRequest, "Audio file not found")
+			return
+		}
+		req.AudioPath = audioPath
+	}
+
+	outputDir := filepath.Join(req.ProjectPath, "videos")
+	if req.ProjectPath == "" {
+		outputDir = filepath.Join(os.TempDir(), "video-maker-clips")
+	}
+
+	if req.DryRun || r.URL.Query().Get("dryRun") == "true" {
+		s.writeVideoDryRun(r.Context(), w, req, size, outputDir)
+		return
+	}
+
+	if !s.ffmpeg.Available {
+		writeJSONError(w, http.StatusServiceUnavailable, "FFmpeg is not installed on this server; install ffmpeg and restart to generate videos")
+		return
+	}
+
+	// Create output directory
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create output directory: "+err.Error())
 		return
 	}
 
-	sceneIndex := r.FormValue("sceneIndex")
-	if sceneIndex == "" {
-		http.Error(w, "sceneIndex is required", http.StatusBadRequest)
-		return
+	// Rendering takes longer than browsers wait, so run it as a job and
+	// let the client poll /api/generate-video/status/{jobId}
+	// Keyed by project ID, not folder, so collab clients see its progress
+	job := s.newJob("generate-video", s.projectIDForPath(req.ProjectPath), []JobItem{{Kind: "clip", Index: req.SceneIndex}})
+	// Respond from a snapshot; the runner updates job as soon as it starts
+	queued, _ := s.getJob(job.ID)
+	go s.runJob(job.ID, "", []jobTask{func() (string, error) {
+		videoURL, err := s.renderSceneVideo(s.jobsCtx, req, size, outputDir, func(percent float64) {
+			s.setJobProgress(job.ID, 0, percent)
+		})
+		if err == nil {
+			s.recordClipMeta(s.jobsCtx, job.ID, 0, videoURL)
+		}
+		return videoURL, err
+	}})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":      true,
+		"jobId":        job.ID,
+		"status":       queued.Status,
+		"statusUrl":    "/api/generate-video/status/" + job.ID,
+		"intermediate": req.Intermediate,
+		"encoding":     req.Encoding,
+	})
+}
+
+// writeVideoDryRun responds with the ffmpeg command a generate-video request
+// would run, without downloading frames or rendering anything. The paths are
+// the ones a real render would use.
+func (s *Server) writeVideoDryRun(ctx context.Context, w http.ResponseWriter, req GenerateVideoRequest, size mediaSize, outputDir string) {
+	framePaths := make([]string, len(req.Frames))
+	for i := range req.Frames {
+		framePaths[i] = sceneFramePath(outputDir, req.SceneIndex, i, len(req.Frames))
+	}
+	clip, err := sceneClipSpec(ctx, req, size, framePaths, filepath.Join(outputDir, fmt.Sprintf("scene_%d%s", req.SceneIndex, req.Encoding.extension())))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	args, err := videoFFmpegArgs(clip)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter := ""
+	if i := slices.IndexFunc(args, func(arg string) bool { return arg == "-filter_complex" || arg == "-vf" }); i >= 0 {
+		filter = args[i+1]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  true,
+		"dryRun":   true,
+		"args":     append([]string{"ffmpeg"}, args...),
+		"filter":   filter,
+		"duration": clip.Duration,
+		"encoding": req.Encoding,
+	})
+}
+
+// sceneFramePath is where frame i of an n-frame scene clip is downloaded.
+func sceneFramePath(outputDir string, sceneIndex, i, n int) string {
+	name := fmt.Sprintf("scene_%d_frame%d.png", sceneIndex, i)
+	switch {
+	case i == 0:
+		name = fmt.Sprintf("scene_%d_first.png", sceneIndex)
+	case i == n-1:
+		name = fmt.Sprintf("scene_%d_last.png", sceneIndex)
+	}
+	return filepath.Join(outputDir, name)
+}
+
+// sceneClipSpec describes the clip a generate-video request renders from the
+// downloaded frames. With narration, the clip is lengthened to fit it.
+func sceneClipSpec(ctx context.Context, req GenerateVideoRequest, size mediaSize, framePaths []string, outputPath string) (clipSpec, error) {
+	clip := clipSpec{
+		Frames:             framePaths,
+		OutputPath:         outputPath,
+		Duration:           req.Duration,
+		Size:               size,
+		Intermediate:       req.Intermediate,
+		ExtraFilters:       req.ExtraFilters,
+		Audio:              req.AudioPath,
+		NarrationStart:     req.NarrationStart,
+		NarrationPadding:   req.NarrationPadding,
+		Effect:             req.Effect,
+		Transition:         req.Transition,
+		TransitionDuration: req.TransitionDuration,
+		Encoding:           req.Encoding,
+	}
+	if clip.Audio != "" {
+		audioDuration, err := probeVideoDuration(ctx, clip.Audio)
+		if err != nil {
+			return clipSpec{}, fmt.Errorf("failed to probe narration audio: %w", err)
+		}
+		clip.Duration = clipDurationForAudio(clip.Duration, audioDuration, clip.NarrationStart, clip.NarrationPadding)
+	}
+	return clip, nil
+}
+
+// renderSceneVideo downloads the frames, renders the clip into outputDir and
+// copies it to /static/videos, returning the static URL.
+func (s *Server) renderSceneVideo(ctx context.Context, req GenerateVideoRequest, size mediaSize, outputDir string, onProgress func(percent float64)) (string, error) {
+	// Download the frames. The first is required; a later one that fails
+	// is dropped and the rest share its time.
+	var framePaths []string
+	for i, url := range req.Frames {
+		framePath := sceneFramePath(outputDir, req.SceneIndex, i, len(req.Frames))
+		if err := s.downloadImage(ctx, url, framePath); err != nil {
+			if i == 0 {
+				return "", fmt.Errorf("failed to download first frame: %w", err)
+			}
+			slog.Warn("Failed to download frame", "frame", i, "error", err)
+			continue
+		}
+		framePaths = append(framePaths, framePath)
 	}
 
-	file, header, err := r.FormFile("video")
+	// Generate video. FFmpeg renders to a hidden file that is renamed into
+	// place when done, so the clip is never seen half-written.
+	ext := req.Encoding.extension()
+	outputPath := filepath.Join(outputDir, fmt.Sprintf("scene_%d%s", req.SceneIndex, ext))
+	renderPath := filepath.Join(outputDir, fmt.Sprintf(".scene_%d.rendering%s", req.SceneIndex, ext))
+	defer os.Remove(renderPath) // no-op once renamed
+	clip, err := sceneClipSpec(ctx, req, size, framePaths, renderPath)
 	if err != nil {
-		http.Error(w, "Failed to get video file: "+err.Error(), http.StatusBadRequest)
-		return
+		return "", err
 	}
-	defer file.Close()
-
-	// Create static videos directory if it doesn't exist
-	staticVideosDir := filepath.Join(s.StaticDir, "videos")
-	if err := os.MkdirAll(staticVideosDir, 0755); err != nil {
-		http.Error(w, "Failed to create videos directory: "+err.Error(), http.StatusInternalServerError)
-		return
+	if err := s.generateVideoWithFFmpeg(ctx, clip, onProgress); err != nil {
+		return "", err
+	}
+	// A project's clip lands under its folder lock, so it can't be moved
+	// into place while rearrangeScenes is renumbering the scene files
+	if req.ProjectPath != "" {
+		lock := s.projectLock(req.ProjectPath)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	if err := os.Rename(renderPath, outputPath); err != nil {
+		return "", err
+	}
+	// A clip rendered in another container before would shadow this one
+	for _, other := range videoExtensions {
+		if other != ext {
+			os.Remove(filepath.Join(outputDir, fmt.Sprintf("scene_%d%s", req.SceneIndex, other)))
+		}
 	}
 
-	// Determine file extension from content type or original filename
-	ext := ".mp4"
-	if header.Filename != "" {
-		if strings.HasSuffix(strings.ToLower(header.Filename), ".webm") {
-			ext = ".webm"
-		} else if strings.HasSuffix(strings.ToLower(header.Filename), ".mov") {
-			ext = ".mov"
+	// Copy to static directory for serving; a lossless intermediate clip
+	// is served as a browser-playable copy instead
+	publish := s.publishToStatic
+	if req.Intermediate {
+		publish = func(srcPath, relURL string) (string, error) {
+			return s.publishPlayable(ctx, srcPath, relURL)
 		}
 	}
+	videoURL, err := publish(outputPath, fmt.Sprintf("videos/scene_%d%s", req.SceneIndex, ext))
+	if err != nil {
+		return "", err
+	}
 
-	// Save with scene index as filename
-	filename := fmt.Sprintf("scene_%s%s", sceneIndex, ext)
-	filePath := filepath.Join(staticVideosDir, filename)
+	slog.Info("generated video", "scene", req.SceneIndex, "path", outputPath, "url", videoURL, "intermediate", req.Intermediate)
+	s.recordGeneration(req.ProjectPath, generatedSceneVideo, req.SceneIndex-1, videoURL, "ffmpeg")
+	return videoURL, nil
+}
 
-	// Read file content
-	videoData, err := io.ReadAll(file)
-	if err != nil {
-		http.Error(w, "Failed to read video data: "+err.Error(), http.StatusInternalServerError)
-		return
+// publishPlayable publishes a lossless intermediate clip to the static dir
+// re-encoded with the delivery settings, so browsers can preview it. The
+// intermediate itself stays in the project folder for later passes.
+func (s *Server) publishPlayable(ctx context.Context, srcPath, relURL string) (string, error) {
+	dstPath := filepath.Join(s.StaticDir, filepath.FromSlash(relURL))
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return "", err
+	}
+	// Encode to a hidden file renamed into place, like the clip itself
+	tmpPath := filepath.Join(filepath.Dir(dstPath), "."+strings.TrimSuffix(filepath.Base(dstPath), filepath.Ext(dstPath))+".transcoding"+filepath.Ext(dstPath))
+	defer os.Remove(tmpPath) // no-op once renamed
+	args := append([]string{"-y", "-i", srcPath}, videoEncodeArgs(false)...)
+	args = append(args, "-c:a", "copy", "-movflags", "+faststart", tmpPath)
+	if _, err := s.runFFmpeg(ctx, args); err != nil {
+		return "", fmt.Errorf("transcode %s for playback: %w", relURL, err)
+	}
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		return "", err
 	}
+	return "/static/" + relURL, nil
+}
 
-	// Write to static directory
-	if err := os.WriteFile(filePath, videoData, 0644); err != nil {
-		http.Error(w, "Failed to save video file: "+err.Error(), http.StatusInternalServerError)
+// HandleGenerateVideoStatus reports a generate-video job; videoUrl is set
+// once the clip is rendered. A render that timed out reports 504.
+func (s *Server) HandleGenerateVideoStatus(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.getJob(r.PathValue("jobId"))
+	if !ok || job.Kind != "generate-video" {
+		writeJSONError(w, http.StatusNotFound, "Job not found")
 		return
 	}
 
-	// Return the static URL
-	staticURL := fmt.Sprintf("/static/videos/%s", filename)
-	slog.Info("uploaded video", "scene", sceneIndex, "path", filePath, "url", staticURL, "size", len(videoData))
-
+	item := job.Items[0]
+	var duration float64
+	if item.Video != nil {
+		duration = item.Video.Duration
+	}
 	w.Header().Set("Content-Type", "application/json")
+	if item.Status == JobFailed && item.TimedOut {
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}
 	json.NewEncoder(w).Encode(map[string]any{
-		"success":  true,
-		"videoUrl": staticURL,
-		"filename": filename,
+		"jobId":      job.ID,
+		"status":     job.Status,
+		"sceneIndex": item.Index,
+		"progress":   item.Progress,
+		"videoUrl":   item.Result,
+		"duration":   duration,
+		"video":      item.Video,
+		"error":      item.Error,
+		"createdAt":  job.CreatedAt,
+		"updatedAt":  job.UpdatedAt,
 	})
 }
 
-func (s *Server) HandleGenerateVideoClips(w http.ResponseWriter, r *http.Request) {
-	var req VideoClipRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+// HandleGenerateVideoEvents streams a generate-video job's progress as
+// Server-Sent Events: "progress" events carry the percent complete, and a
+// final "done" event carries the video URL or error before the stream closes.
+func (s *Server) HandleGenerateVideoEvents(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("jobId")
+	job, changed, ok := s.watchJob(jobID)
+	if !ok || job.Kind != "generate-video" {
+		writeJSONError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Streaming unsupported")
 		return
 	}
 
-	clips := make([]VideoClip, len(req.Scenes))
-	
-	for i, scene := range req.Scenes {
-		// TODO: Integrate Veo 3 API
-		// 
-		// Veo 3 integration would:
-		// 1. Upload start frame (and end frame if provided)
-		// 2. Send prompt for video generation
-		// 3. Poll for completion
-		// 4. Return video URL
-		//
-		// API call would include:
-		// - scene.StartFrame (image URL or base64)
-		// - scene.EndFrame (optional, for transitions)
-		// - scene.Prompt (text description for video)
-		// - scene.Narration (for context)
-		
-		hasEndFrame := scene.EndFrame != nil && *scene.EndFrame != ""
-		
-		clips[i] = VideoClip{
-			SceneIndex:  scene.Index,
-			VideoURL:    generatePlaceholderVideo(scene.Index),
-			PosterURL:   scene.StartFrame,
-			Duration:    "~5s",
-			HasEndFrame: hasEndFrame,
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	lastPercent := -1.0
+	for {
+		item := job.Items[0]
+		if item.Progress != lastPercent {
+			writeEvent(w, "progress", map[string]any{
+				"status":  job.Status,
+				"percent": item.Progress,
+			})
+			lastPercent = item.Progress
 		}
-	}
+		if job.Status == JobDone || job.Status == JobFailed {
+			writeEvent(w, "done", map[string]any{
+				"status":   job.Status,
+				"videoUrl": item.Result,
+				"error":    item.Error,
+			})
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"clips":   clips,
-		"message": "Video clips generated (placeholder - Veo 3 integration pending)",
-	})
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+		}
+		if job, changed, ok = s.watchJob(jobID); !ok {
+			return
+		}
+	}
 }
 
-func generatePlaceholderVideo(sceneIndex int) string {
-	return fmt.Sprintf("https://storage.googleapis.com/gtv-videos-bucket/sample/ForBiggerEscapes.mp4#scene=%d", sceneIndex)
+// writeEvent writes one Server-Sent Event with a JSON payload.
+func writeEvent(w io.Writer, event string, data any) {
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
 }
 
-// Actual video generation using FFmpeg
-type GenerateVideoRequest struct {
-	ProjectPath    string `json:"projectPath"`
-	SceneIndex     int    `json:"sceneIndex"`
-	FirstFrameURL  string `json:"firstFrameUrl"`
-	LastFrameURL   string `json:"lastFrameUrl"`
-	Duration       int    `json:"duration"`
-	Prompt         string `json:"prompt"`
+// videoEncodeArgs returns the ffmpeg video codec flags for a clip. Intermediate
+// clips use lossless x264 (QP 0) so they can be re-encoded by later pipeline
+// steps without generational loss; delivery clips use the browser-friendly
+// default. Lossless x264 needs the High 4:4:4 Predictive profile, which
+// browsers won't play, so intermediate files must never be served as is.
+func videoEncodeArgs(intermediate bool) []string {
+	if intermediate {
+		return []string{"-c:v", "libx264", "-qp", "0", "-preset", "ultrafast", "-pix_fmt", "yuv420p"}
+	}
+	return []string{"-c:v", "libx264", "-pix_fmt", "yuv420p"}
 }
 
-func (s *Server) HandleGenerateVideo(w http.ResponseWriter, r *http.Request) {
-	var req GenerateVideoRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
-		return
+// minCrossfadeDuration is the shortest clip that can still fit a crossfade
+// between its frames.
+const minCrossfadeDuration = 1
+
+// maxClipFrames caps how many images one scene clip crossfades through.
+const maxClipFrames = 16
+
+// crossfadeTiming splits a clip over n images into n overlapping segments
+// joined by n-1 fades. Unless a fade length is requested, each fade lasts up
+// to a second, shrinking for short clips so no offset goes negative. The
+// k-th fade starts at k*step, and the last segment ends exactly at the clip
+// duration.
+func crossfadeTiming(duration, n int, requested float64) (segment, fade, step float64, err error) {
+	if duration < minCrossfadeDuration {
+		return 0, 0, 0, fmt.Errorf("duration %ds is too short to crossfade (minimum %ds)", duration, minCrossfadeDuration)
 	}
-
-	if req.FirstFrameURL == "" {
-		http.Error(w, "First frame URL is required", http.StatusBadRequest)
-		return
+	if n < 2 {
+		return 0, 0, 0, fmt.Errorf("a crossfade needs at least 2 frames, got %d", n)
 	}
-
-	if req.Duration <= 0 {
-		req.Duration = 5
+	fade = math.Min(1, float64(duration)/float64(n))
+	if requested > 0 {
+		fade = math.Min(requested, float64(duration))
 	}
+	segment = (float64(duration) + float64(n-1)*fade) / float64(n)
+	step = segment - fade
+	return segment, fade, step, nil
+}
 
-	// Create output directory
-	outputDir := filepath.Join(req.ProjectPath, "videos")
-	if req.ProjectPath == "" {
-		outputDir = filepath.Join(os.TempDir(), "video-maker-clips")
+// clipSpec describes one scene clip to render with FFmpeg.
+type clipSpec struct {
+	FirstFrame         string
+	LastFrame          string
+	// Frames, when set, are all the images to crossfade through in order
+	// and replace FirstFrame and LastFrame
+	Frames             []string
+	OutputPath         string
+	Duration           int
+	// Size is the output frame size; zero means defaultOutputSize
+	Size               mediaSize
+	Intermediate       bool
+	// ExtraFilters are validated user filter steps appended to the chain
+	ExtraFilters       string
+	// Audio is an optional narration track, offset by NarrationStart and
+	// NarrationPadding (see narrationFilter)
+	Audio              string
+	NarrationStart     float64
+	NarrationPadding   float64
+	// Effect is the Ken Burns move for a single image; zero means the
+	// default preset
+	Effect             Effect
+	// Transition is the xfade transition between consecutive frames, each
+	// lasting TransitionDuration seconds; zero values mean a fade of up to 1s
+	Transition         string
+	TransitionDuration float64
+	// Encoding sets the delivery codec; ignored for intermediate clips
+	Encoding           Encoding
+}
+
+// frames returns the images the clip is built from, in order.
+func (clip clipSpec) frames() []string {
+	if len(clip.Frames) > 0 {
+		return clip.Frames
 	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		http.Error(w, "Failed to create output directory: "+err.Error(), http.StatusInternalServerError)
-		return
+	if clip.LastFrame != "" {
+		return []string{clip.FirstFrame, clip.LastFrame}
 	}
+	return []string{clip.FirstFrame}
+}
 
-	// Download first frame
-	firstFramePath := filepath.Join(outputDir, fmt.Sprintf("scene_%d_first.png", req.SceneIndex))
-	if err := downloadImage(req.FirstFrameURL, firstFramePath); err != nil {
-		http.Error(w, "Failed to download first frame: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
+// defaultOutputSize is the clip frame size when a request doesn't pick one.
+var defaultOutputSize = mediaSize{Width: 1920, Height: 1080}
+
+// outputSizes are the clip frame sizes generate-video accepts.
+var outputSizes = []mediaSize{
+	{1920, 1080}, {1280, 720}, {3840, 2160}, // 16:9
+	{1080, 1920}, {720, 1280}, // 9:16
+	{1080, 1080}, {720, 720}, // 1:1
+	{1080, 1350}, // 4:5
+	{1440, 1080}, // 4:3
+}
+
+// aspectRatioSizes maps an aspect ratio to its default frame size.
+var aspectRatioSizes = map[string]mediaSize{
+	"16:9": {1920, 1080},
+	"9:16": {1080, 1920},
+	"1:1":  {1080, 1080},
+	"4:5":  {1080, 1350},
+	"4:3":  {1440, 1080},
+}
 
-	// Download last frame if provided
-	lastFramePath := ""
-	if req.LastFrameURL != "" {
-		lastFramePath = filepath.Join(outputDir, fmt.Sprintf("scene_%d_last.png", req.SceneIndex))
-		if err := downloadImage(req.LastFrameURL, lastFramePath); err != nil {
-			slog.Warn("Failed to download last frame", "error", err)
-			lastFramePath = ""
+// outputSize resolves a request's resolution and aspect ratio to an allowed
+// frame size. When both are given they must agree.
+func outputSize(resolution, aspectRatio string) (mediaSize, error) {
+	ratioSize, hasRatio := aspectRatioSizes[aspectRatio]
+	if aspectRatio != "" && !hasRatio {
+		ratios := slices.Sorted(maps.Keys(aspectRatioSizes))
+		return mediaSize{}, fmt.Errorf("unsupported aspect ratio %q (supported: %s)", aspectRatio, strings.Join(ratios, ", "))
+	}
+	if resolution == "" {
+		if hasRatio {
+			return ratioSize, nil
 		}
+		return defaultOutputSize, nil
 	}
 
-	// Generate video
-	outputPath := filepath.Join(outputDir, fmt.Sprintf("scene_%d.mp4", req.SceneIndex))
-	if err := generateVideoWithFFmpeg(firstFramePath, lastFramePath, outputPath, req.Duration); err != nil {
-		http.Error(w, "Failed to generate video: "+err.Error(), http.StatusInternalServerError)
-		return
+	size, ok := parseResolution(resolution)
+	if !ok || !slices.Contains(outputSizes, size) {
+		supported := make([]string, len(outputSizes))
+		for i, allowed := range outputSizes {
+			supported[i] = fmt.Sprintf("%dx%d", allowed.Width, allowed.Height)
+		}
+		return mediaSize{}, fmt.Errorf("unsupported resolution %q (supported: %s)", resolution, strings.Join(supported, ", "))
 	}
+	if hasRatio && size.Width*ratioSize.Height != size.Height*ratioSize.Width {
+		return mediaSize{}, fmt.Errorf("resolution %s doesn't match aspect ratio %s", resolution, aspectRatio)
+	}
+	return size, nil
+}
 
-	// Return the video URL
-	videoURL := fmt.Sprintf("/static/videos/scene_%d.mp4", req.SceneIndex)
-	
-	// Copy to static directory for serving
-	staticVideoDir := filepath.Join(s.StaticDir, "videos")
-	os.MkdirAll(staticVideoDir, 0755)
-	staticVideoPath := filepath.Join(staticVideoDir, fmt.Sprintf("scene_%d.mp4", req.SceneIndex))
-	
-	// Copy file
-	input, _ := os.ReadFile(outputPath)
-	os.WriteFile(staticVideoPath, input, 0644)
-
-	slog.Info("generated video", "scene", req.SceneIndex, "path", staticVideoPath)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"success":  true,
-		"videoUrl": videoURL,
-		"status":   "ready",
-		"message":  "Video generated!",
-	})
+// clipDurationForAudio returns the clip length in whole seconds needed to
+// play the narration in full, never shorter than the requested duration.
+func clipDurationForAudio(duration int, audioSeconds, start, padding float64) int {
+	return max(duration, int(math.Ceil(start+audioSeconds+padding)))
 }
 
-func downloadImage(url string, destPath string) error {
-	// Handle base64 data URLs
-	if strings.HasPrefix(url, "data:image") {
-		return saveBase64Image(url, destPath)
+// videoFFmpegArgs builds the ffmpeg arguments for a scene clip: a chain of
+// crossfades through two or more frames, or a Ken Burns move over a single
+// image.
+func videoFFmpegArgs(clip clipSpec) ([]string, error) {
+	var args []string
+	encodeArgs := videoEncodeArgs(true)
+	if !clip.Intermediate {
+		encodeArgs = clip.Encoding.args()
 	}
+	images, outputPath, duration := clip.frames(), clip.OutputPath, clip.Duration
 
-	// Handle regular URLs
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
+	extra := ""
+	if clip.ExtraFilters != "" {
+		if err := validateExtraFilters(clip.ExtraFilters); err != nil {
+			return nil, err
+		}
+		extra = "," + clip.ExtraFilters
 	}
-	defer resp.Body.Close()
 
-	out, err := os.Create(destPath)
-	if err != nil {
-		return err
+	size := clip.Size
+	if size == (mediaSize{}) {
+		size = defaultOutputSize
 	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
-}
+	// fit letterboxes a frame into the output size
+	fit := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1",
+		size.Width, size.Height, size.Width, size.Height)
+	dims := fmt.Sprintf("%dx%d", size.Width, size.Height)
 
-func generateVideoWithFFmpeg(firstFrame, lastFrame, outputPath string, duration int) error {
-	var cmd *exec.Cmd
-
-	if lastFrame != "" {
-		// Cross-fade between two images (image-to-image)
-		// Creates a smooth transition from first to last frame
-		filter := fmt.Sprintf(
-			"[0:v]scale=1920:1080:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2,setsar=1,zoompan=z='min(zoom+0.0015,1.2)':d=%d*30:s=1920x1080:fps=30[v0];" +
-			"[1:v]scale=1920:1080:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2,setsar=1,zoompan=z='if(lte(zoom,1.0),1.2,max(1.001,zoom-0.0015))':d=%d*30:s=1920x1080:fps=30[v1];" +
-			"[v0][v1]xfade=transition=fade:duration=1:offset=%d[outv]",
-			duration/2, duration/2, duration/2-1,
-		)
-		cmd = exec.Command("ffmpeg", "-y",
-			"-loop", "1", "-i", firstFrame,
-			"-loop", "1", "-i", lastFrame,
-			"-filter_complex", filter,
-			"-map", "[outv]",
-			"-c:v", "libx264", "-pix_fmt", "yuv420p",
-			"-t", fmt.Sprintf("%d", duration),
-			outputPath,
-		)
+	if len(images) > 1 {
+		filter, err := crossfadeFilter(len(images), duration, clip.Transition, clip.TransitionDuration, fit, dims, extra)
+		if err != nil {
+			return nil, err
+		}
+		args = []string{"-y"}
+		for _, image := range images {
+			args = append(args, "-loop", "1", "-i", image)
+		}
+		if clip.Audio != "" {
+			audioFilter, err := clipAudioFilter(len(images), clip)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, "-i", clip.Audio,
+				"-filter_complex", filter+";"+audioFilter,
+				"-map", "[outv]", "-map", "[outa]",
+			)
+			args = append(args, encodeArgs...)
+			args = append(args, clip.Encoding.audioArgs()...)
+		} else {
+			args = append(args, "-filter_complex", filter, "-map", "[outv]")
+			args = append(args, encodeArgs...)
+		}
+		args = append(args, "-t", fmt.Sprintf("%d", duration), outputPath)
 	} else {
+		firstFrame := images[0]
 		// Ken Burns effect on single image (zoom and pan)
+		effect, err := clip.Effect.resolve()
+		if err != nil {
+			return nil, err
+		}
 		filter := fmt.Sprintf(
-			"scale=1920:1080:force_original_aspect_ratio=decrease,pad=1920:1080:(ow-iw)/2:(oh-ih)/2,setsar=1," +
-			"zoompan=z='min(zoom+0.001,1.3)':x='iw/2-(iw/zoom/2)':y='ih/2-(ih/zoom/2)':d=%d*30:s=1920x1080:fps=30",
-			duration,
+			"%s," +
+			"zoompan=%s:d=%d*30:s=%s:fps=30%s",
+			fit, effect.zoompan(duration*30), duration, dims, extra,
 		)
-		cmd = exec.Command("ffmpeg", "-y",
+		args = []string{"-y",
 			"-loop", "1", "-i", firstFrame,
-			"-vf", filter,
-			"-c:v", "libx264", "-pix_fmt", "yuv420p",
-			"-t", fmt.Sprintf("%d", duration),
-			outputPath,
-		)
+		}
+		if clip.Audio != "" {
+			audioFilter, err := clipAudioFilter(1, clip)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, "-i", clip.Audio,
+				"-filter_complex", "[0:v]"+filter+"[outv];"+audioFilter,
+				"-map", "[outv]", "-map", "[outa]",
+			)
+			args = append(args, encodeArgs...)
+			args = append(args, clip.Encoding.audioArgs()...)
+		} else {
+			args = append(args, "-vf", filter)
+			args = append(args, encodeArgs...)
+		}
+		args = append(args, "-t", fmt.Sprintf("%d", duration), outputPath)
 	}
+	return args, nil
+}
 
-	output, err := cmd.CombinedOutput()
+// crossfadeFilter builds the filtergraph that crossfades through n looped
+// images (inputs 0..n-1): each gets a slow zoom over its segment, then the
+// segments are chained with xfade into [outv]. The first frames zoom in and
+// the last zooms out, so a two-frame clip drifts in and back.
+func crossfadeFilter(n, duration int, transition string, requested float64, fit, dims, extra string) (string, error) {
+	segment, fade, step, err := crossfadeTiming(duration, n, requested)
 	if err != nil {
-		slog.Error("ffmpeg failed", "error", err, "output", string(output))
-		return fmt.Errorf("ffmpeg error: %v - %s", err, string(output))
+		return "", err
 	}
+	if transition == "" {
+		transition = defaultTransition
+	}
+	frames := max(1, int(math.Round(segment*30)))
 
-	return nil
+	var parts []string
+	for i := range n {
+		zoom := "min(zoom+0.0015,1.2)"
+		if i == n-1 {
+			zoom = "if(lte(zoom,1.0),1.2,max(1.001,zoom-0.0015))"
+		}
+		parts = append(parts, fmt.Sprintf("[%d:v]%s,zoompan=z='%s':d=%d:s=%s:fps=30[v%d]", i, fit, zoom, frames, dims, i))
+	}
+	// Each xfade's offset is measured on the chain built so far, which
+	// grows by one step per frame
+	prev := "[v0]"
+	for k := 1; k < n; k++ {
+		out, tail := fmt.Sprintf("[x%d]", k), ""
+		if k == n-1 {
+			out, tail = "[outv]", extra
+		}
+		parts = append(parts, fmt.Sprintf("%s[v%d]xfade=transition=%s:duration=%g:offset=%g%s%s",
+			prev, k, transition, fade, float64(k)*step, tail, out))
+		prev = out
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+// clipAudioFilter offsets the narration input and pads it to [outa].
+func clipAudioFilter(input int, clip clipSpec) (string, error) {
+	narration, err := narrationFilter(input, clip.NarrationStart, clip.NarrationPadding, float64(clip.Duration), "narr")
+	if err != nil {
+		return "", err
+	}
+	return narration + ";[narr]apad[outa]", nil
+}
+
+// generateVideoWithFFmpeg renders clip, reporting percent complete against
+// its duration to onProgress if set.
+func (s *Server) generateVideoWithFFmpeg(ctx context.Context, clip clipSpec, onProgress func(percent float64)) error {
+	args, err := videoFFmpegArgs(clip)
+	if err != nil {
+		return err
+	}
+	var report func(ffmpegProgress)
+	if onProgress != nil {
+		report = func(p ffmpegProgress) {
+			if p.Done {
+				onProgress(100)
+				return
+			}
+			onProgress(min(100, 100*p.OutTime.Seconds()/float64(clip.Duration)))
+		}
+	}
+	_, err = s.runFFmpegProgress(ctx, args, report)
+	return err
 }
 
 // Save project types
 type SaveProjectRequest struct {
 	ProjectPath   string                   `json:"projectPath"`
+	// ProjectID links the save to an in-memory project's recorded generations
+	ProjectID     string                   `json:"projectId"`
 	StoryPrompt   string                   `json:"storyPrompt"`
 	Characters    []map[string]any         `json:"characters"`
 	ArtImages  []map[string]any         `json:"artImages"`
@@ -635,18 +3016,42 @@ type SaveProjectRequest struct {
 	ImageProvider string                   `json:"imageProvider"`
 	Settings      map[string]any           `json:"settings"`
 	SavedAt       string                   `json:"savedAt"`
+	// BaseVersion is the project.json version the client last loaded or
+	// saved; a stale one gets a 409 (If-Match works too)
+	BaseVersion *int64 `json:"baseVersion"`
 }
 
 func (s *Server) HandleSaveProject(w http.ResponseWriter, r *http.Request) {
 	var req SaveProjectRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &req, maxMediaJSONBody) {
 		return
 	}
 
-	projectPath := req.ProjectPath
-	if projectPath == "" {
-		http.Error(w, "Project path is required", http.StatusBadRequest)
+	if req.ProjectPath == "" {
+		writeJSONError(w, http.StatusBadRequest, "Project path is required")
+		return
+	}
+	projectPath, err := resolveProjectPath(s.ProjectsRoot, req.ProjectPath)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid project path: "+err.Error())
+		return
+	}
+	req.ProjectPath = projectPath
+	for _, err := range []error{checkMediaFiles("art image", req.ArtImages), checkMediaFiles("scene", req.Scenes)} {
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	lock := s.projectLock(projectPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Refuse a save based on an older project.json before touching any files
+	jsonPath := filepath.Join(projectPath, "project.json")
+	version, ok := checkVersion(w, r, jsonPath, req.BaseVersion)
+	if !ok {
 		return
 	}
 
@@ -655,17 +3060,26 @@ func (s *Server) HandleSaveProject(w http.ResponseWriter, r *http.Request) {
 	videosDir := filepath.Join(projectPath, "videos")
 	
 	if err := os.MkdirAll(imagesDir, 0755); err != nil {
-		http.Error(w, "Failed to create images directory: "+err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create images directory: "+err.Error())
 		return
 	}
 	if err := os.MkdirAll(videosDir, 0755); err != nil {
-		http.Error(w, "Failed to create videos directory: "+err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create videos directory: "+err.Error())
 		return
 	}
 
 	imageCount := 0
 	videoCount := 0
 
+	// Fill in anything generated since the client's last save, e.g. results
+	// that finished after a crash or reload
+	generationKeys := []string{projectPath, req.ProjectID}
+	var recovered int
+	req.ArtImages, recovered = s.reconcileGenerations(r.Context(), generationKeys, req.Scenes, req.ArtImages)
+	if recovered > 0 {
+		slog.Info("recovered generation results", "project", projectPath, "count", recovered)
+	}
+
 	// Save character art images
 	for i, art := range req.ArtImages {
 		imageURL, ok := art["imageUrl"].(string)
@@ -682,12 +3096,13 @@ func (s *Server) HandleSaveProject(w http.ResponseWriter, r *http.Request) {
 		imagePath := filepath.Join(imagesDir, filename)
 
 		if strings.HasPrefix(imageURL, "data:image") {
-			if err := saveBase64Image(imageURL, imagePath); err != nil {
+			imagePath, err := saveBase64Image(imageURL, imagePath)
+			if err != nil {
 				slog.Warn("failed to save character image", "error", err, "index", index)
 				continue
 			}
 			// Update the art entry with the filename (not the data URL)
-			req.ArtImages[i]["imageFile"] = filename
+			req.ArtImages[i]["imageFile"] = filepath.Base(imagePath)
 			imageCount++
 		}
 	}
@@ -707,20 +3122,33 @@ func (s *Server) HandleSaveProject(w http.ResponseWriter, r *http.Request) {
 		filename := fmt.Sprintf("scene_%d.png", i+1)
 		imagePath := filepath.Join(keyframesDir, filename)
 
+		imageChanged := false
 		if strings.HasPrefix(imageURL, "data:image") {
-			if err := saveBase64Image(imageURL, imagePath); err != nil {
+			saved, err := saveBase64Image(imageURL, imagePath)
+			if err != nil {
 				slog.Warn("failed to save scene image", "error", err, "scene", i+1)
 				continue
 			}
+			imagePath = saved
 			// Update the scene entry with the filename
-			req.Scenes[i]["imageFile"] = filename
+			req.Scenes[i]["imageFile"] = filepath.Base(imagePath)
 			imageCount++
+			imageChanged = true
+		} else if imageFile, ok := scene["imageFile"].(string); ok && imageFile != "" {
+			imagePath = filepath.Join(keyframesDir, imageFile)
+		}
+
+		// Record dimensions once, re-probing only when the image was rewritten
+		if _, known := scene["imageSize"]; imageChanged || !known {
+			if size, err := probeImageSize(imagePath); err == nil {
+				req.Scenes[i]["imageSize"] = size
+			}
 		}
 
 		// Save video if it exists
 		videoURL, hasVideo := scene["videoUrl"].(string)
 		if hasVideo && videoURL != "" {
-			videoFilename := fmt.Sprintf("scene_%d.mp4", i+1)
+			videoFilename := fmt.Sprintf("scene_%d%s", i+1, videoExtension(videoURL))
 			videoPath := filepath.Join(videosDir, videoFilename)
 			
 			if strings.HasPrefix(videoURL, "data:") {
@@ -730,22 +3158,47 @@ func (s *Server) HandleSaveProject(w http.ResponseWriter, r *http.Request) {
 				} else {
 					req.Scenes[i]["videoFile"] = videoFilename
 					videoCount++
+					delete(req.Scenes[i], "videoSize")
+					delete(req.Scenes[i], "videoMeta")
 				}
 			} else if strings.HasPrefix(videoURL, "blob:") {
 				// Skip blob URLs - they need to be uploaded separately
 				slog.Info("skipping blob URL for scene video", "scene", i+1)
 			} else if strings.HasPrefix(videoURL, "/static/videos/") || strings.HasPrefix(videoURL, "http") {
 				// Download from URL
-				if err := downloadVideo(videoURL, videoPath, s.StaticDir); err != nil {
+				if err := s.downloadVideo(r.Context(), videoURL, videoPath); err != nil {
 					slog.Warn("failed to download scene video", "error", err, "scene", i+1)
 				} else {
 					req.Scenes[i]["videoFile"] = videoFilename
 					videoCount++
+					delete(req.Scenes[i], "videoSize")
+					delete(req.Scenes[i], "videoMeta")
+				}
+			}
+		}
+
+		if videoFile, ok := scene["videoFile"].(string); ok && videoFile != "" {
+			_, knownSize := scene["videoSize"]
+			if _, knownMeta := scene["videoMeta"]; !knownSize || !knownMeta {
+				if meta, err := probeVideo(r.Context(), filepath.Join(videosDir, videoFile)); err == nil {
+					req.Scenes[i]["videoSize"] = meta.size()
+					req.Scenes[i]["videoMeta"] = meta
+				} else {
+					slog.Debug("could not probe scene video size", "error", err, "scene", i+1)
 				}
 			}
 		}
 	}
 
+	// Persist each scene's pipeline status alongside its media
+	for i, scene := range req.Scenes {
+		imageFile, _ := scene["imageFile"].(string)
+		imageURL, _ := scene["imageUrl"].(string)
+		videoFile, _ := scene["videoFile"].(string)
+		current, _ := scene["status"].(string)
+		req.Scenes[i]["status"] = sceneStatusFor(SceneStatus(current), imageFile != "" || imageURL != "", videoFile != "")
+	}
+
 	// Build project data for JSON (without base64 data URLs)
 	projectData := map[string]any{
 		"storyPrompt":   req.StoryPrompt,
@@ -757,26 +3210,31 @@ func (s *Server) HandleSaveProject(w http.ResponseWriter, r *http.Request) {
 		"imageProvider": req.ImageProvider,
 		"settings":      req.Settings,
 		"savedAt":       time.Now().Format(time.RFC3339),
+		"version":       version,
 	}
 
 	// Save project.json
-	jsonPath := filepath.Join(projectPath, "project.json")
 	jsonData, err := json.MarshalIndent(projectData, "", "  ")
 	if err != nil {
-		http.Error(w, "Failed to create project JSON: "+err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create project JSON: "+err.Error())
 		return
 	}
 
-	if err := os.WriteFile(jsonPath, jsonData, 0644); err != nil {
-		http.Error(w, "Failed to save project file: "+err.Error(), http.StatusInternalServerError)
+	// Written atomically: a crash mid-save must not leave a truncated
+	// project.json behind
+	if _, err := writeFileAtomic(jsonPath, bytes.NewReader(jsonData), 0644); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save project file: "+err.Error())
 		return
 	}
+	s.clearGenerations(r.Context(), generationKeys)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{
 		"projectPath": projectPath,
 		"imageCount":  imageCount,
 		"videoCount":  videoCount,
+		"recovered":   recovered,
+		"version":     version,
 	})
 }
 
@@ -786,37 +3244,62 @@ func (s *Server) HandleSaveEditorProject(w http.ResponseWriter, r *http.Request)
 		ProjectPath   string         `json:"projectPath"`
 		EditorProject map[string]any `json:"editorProject"`
 		Filename      string         `json:"filename"`
+		// BaseVersion guards against overwriting a newer save, as for
+		// save-project
+		BaseVersion *int64 `json:"baseVersion"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &req, maxMediaJSONBody) {
 		return
 	}
 
 	if req.ProjectPath == "" {
-		http.Error(w, "Project path is required", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Project path is required")
 		return
 	}
-
-	// Create project directory if it doesn't exist
-	if err := os.MkdirAll(req.ProjectPath, 0755); err != nil {
-		http.Error(w, "Failed to create project directory: "+err.Error(), http.StatusInternalServerError)
+	projectPath, err := resolveProjectPath(s.ProjectsRoot, req.ProjectPath)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid project path: "+err.Error())
 		return
 	}
+	req.ProjectPath = projectPath
 
 	// Use provided filename or default to videoedit.vproj
 	filename := req.Filename
 	if filename == "" {
 		filename = "videoedit.vproj"
 	}
+	if !isBaseName(filename) || filepath.Ext(filename) != ".vproj" {
+		writeJSONError(w, http.StatusBadRequest, "Filename must be a plain .vproj file name")
+		return
+	}
+
+	lock := s.projectLock(req.ProjectPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Create project directory if it doesn't exist
+	if err := os.MkdirAll(req.ProjectPath, 0755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create project directory: "+err.Error())
+		return
+	}
+
 	jsonPath := filepath.Join(req.ProjectPath, filename)
+	version, ok := checkVersion(w, r, jsonPath, req.BaseVersion)
+	if !ok {
+		return
+	}
+	if req.EditorProject == nil {
+		req.EditorProject = map[string]any{}
+	}
+	req.EditorProject[projectVersionKey] = version
 	jsonData, err := json.MarshalIndent(req.EditorProject, "", "  ")
 	if err != nil {
-		http.Error(w, "Failed to create JSON: "+err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create JSON: "+err.Error())
 		return
 	}
 
-	if err := os.WriteFile(jsonPath, jsonData, 0644); err != nil {
-		http.Error(w, "Failed to write editor project: "+err.Error(), http.StatusInternalServerError)
+	if _, err := writeFileAtomic(jsonPath, bytes.NewReader(jsonData), 0644); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to write editor project: "+err.Error())
 		return
 	}
 
@@ -826,6 +3309,7 @@ func (s *Server) HandleSaveEditorProject(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(map[string]any{
 		"success": true,
 		"path":    jsonPath,
+		"version": version,
 	})
 }
 
@@ -855,43 +3339,85 @@ func (s *Server) HandleLoadProject(w http.ResponseWriter, r *http.Request) {
 	projectPath := r.URL.Query().Get("path")
 	
 	if projectPath == "" {
-		http.Error(w, "Missing path parameter", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Missing path parameter")
+		return
+	}
+	projectPath, err := resolveProjectPath(s.ProjectsRoot, projectPath)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid project path: "+err.Error())
 		return
 	}
+
+	// Hold the project's read lock so a concurrent save can't hand us a
+	// half-written project.json
+	lock := s.projectLock(projectPath)
+	lock.RLock()
+	defer lock.RUnlock()
 	
 	jsonPath := filepath.Join(projectPath, "project.json")
 	imagesDir := filepath.Join(projectPath, "images")
+
+	// Images are published to the static dir and referenced by URL, like
+	// videos; ?inline=true embeds them as base64 data URLs instead
+	inline := r.URL.Query().Get("inline") == "true"
+	staticDir := projectStaticDir(projectPath)
+	imageURL := func(imgPath string) (string, error) {
+		if inline {
+			imgData, err := os.ReadFile(imgPath)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("data:%s;base64,%s", detectMimeType(imgPath), base64.StdEncoding.EncodeToString(imgData)), nil
+		}
+		url, err := s.publishToStatic(imgPath, staticDir+"/"+filepath.Base(imgPath))
+		if err != nil && !os.IsNotExist(err) {
+			slog.Warn("failed to publish project image", "path", imgPath, "error", err)
+		}
+		return url, err
+	}
 	
 	// Read project JSON
+	// Tell "no such project" (404) from a broken disk (500) and a corrupt
+	// project.json (422); the category says which
 	jsonData, err := os.ReadFile(jsonPath)
-	if err != nil {
-		http.Error(w, "Project not found: "+err.Error(), http.StatusNotFound)
+	if os.IsNotExist(err) {
+		writeJSONErrorFields(w, http.StatusNotFound, "Project not found: no project.json in "+projectPath, map[string]any{"category": "not_found"})
+		return
+	} else if err != nil {
+		slog.Error("failed to read project file", "path", jsonPath, "error", err)
+		writeJSONErrorFields(w, http.StatusInternalServerError, "Failed to read project file: "+err.Error(), map[string]any{"category": "io_error"})
 		return
 	}
 	
 	var project map[string]any
-	if err := json.Unmarshal(jsonData, &project); err != nil {
-		http.Error(w, "Invalid project file: "+err.Error(), http.StatusInternalServerError)
+	if err := json.Unmarshal(jsonData, &project); err != nil || project == nil {
+		if err == nil {
+			err = errors.New("not a JSON object")
+		}
+		slog.Warn("invalid project file", "path", jsonPath, "error", err)
+		writeJSONErrorFields(w, http.StatusUnprocessableEntity, "Invalid project file: "+err.Error(), map[string]any{"category": "invalid_json"})
 		return
 	}
 	
-	// Load character art images from disk and convert to base64
+	// Load character art images from disk
 	if artImages, ok := project["artImages"].([]any); ok {
 		for i, art := range artImages {
 			if artMap, ok := art.(map[string]any); ok {
 				// Try to load image by imageFile first, then by index
 				var imgPath string
+				// File names are checked on save, but project.json may
+				// have been edited since
 				if imageFile, ok := artMap["imageFile"].(string); ok && imageFile != "" {
-					imgPath = filepath.Join(imagesDir, imageFile)
+					if isBaseName(imageFile) {
+						imgPath = filepath.Join(imagesDir, imageFile)
+					}
 				} else if idx, ok := artMap["index"].(float64); ok {
-					imgPath = filepath.Join(imagesDir, fmt.Sprintf("character_%d.png", int(idx)))
+					imgPath = filepath.Join(imagesDir, findImageFile(imagesDir, fmt.Sprintf("character_%d", int(idx))))
 				}
 				
 				if imgPath != "" {
-					if imgData, err := os.ReadFile(imgPath); err == nil {
-						mimeType := detectMimeType(imgPath)
-						base64Data := base64.StdEncoding.EncodeToString(imgData)
-						artMap["imageUrl"] = fmt.Sprintf("data:%s;base64,%s", mimeType, base64Data)
+					if url, err := imageURL(imgPath); err == nil {
+						artMap["imageUrl"] = url
 						artImages[i] = artMap
 					}
 				}
@@ -909,47 +3435,61 @@ func (s *Server) HandleLoadProject(w http.ResponseWriter, r *http.Request) {
 			if sceneMap, ok := scene.(map[string]any); ok {
 				// Try to load image by imageFile first, then by index
 				var imgPath string
-				filename := ""
-				if imageFile, ok := sceneMap["imageFile"].(string); ok && imageFile != "" {
-					filename = imageFile
-				} else {
-					filename = fmt.Sprintf("scene_%d.png", i+1)
+				// Without an imageFile, scene_N is found in whichever format
+				// it was saved as
+				stem := fmt.Sprintf("scene_%d", i+1)
+				imageFile, _ := sceneMap["imageFile"].(string)
+				if imageFile != "" && !isBaseName(imageFile) {
+					slog.Warn("ignoring scene image outside the project", "path", projectPath, "scene", i+1, "file", imageFile)
+					imageFile = ""
+				}
+				filename := imageFile
+				if imageFile == "" {
+					filename = findImageFile(keyframesDir, stem)
 				}
 				
 				// Try keyframes directory first
 				imgPath = filepath.Join(keyframesDir, filename)
 				if _, err := os.Stat(imgPath); os.IsNotExist(err) {
 					// Fall back to images directory for backward compatibility
+					if imageFile == "" {
+						filename = findImageFile(imagesDir, stem)
+					}
 					imgPath = filepath.Join(imagesDir, filename)
 				}
 				
 				if imgPath != "" {
-					if imgData, err := os.ReadFile(imgPath); err == nil {
-						mimeType := detectMimeType(imgPath)
-						base64Data := base64.StdEncoding.EncodeToString(imgData)
-						sceneMap["imageUrl"] = fmt.Sprintf("data:%s;base64,%s", mimeType, base64Data)
+					if url, err := imageURL(imgPath); err == nil {
+						sceneMap["imageUrl"] = url
 					}
 				}
 				
 				// Try to load video by videoFile first, then by index
 				videoFilename := ""
-				if videoFile, ok := sceneMap["videoFile"].(string); ok && videoFile != "" {
+				if videoFile, ok := sceneMap["videoFile"].(string); ok && isBaseName(videoFile) {
 					videoFilename = videoFile
 				} else {
-					videoFilename = fmt.Sprintf("scene_%d.mp4", i+1)
+					videoFilename = findSceneVideo(videosDir, i+1)
 				}
 				
+				hasImage := sceneMap["imageUrl"] != nil && sceneMap["imageUrl"] != ""
+				hasVideo := false
 				videoPath := filepath.Join(videosDir, videoFilename)
 				if _, err := os.Stat(videoPath); err == nil {
+					hasVideo = true
+					sceneMap["videoFile"] = videoFilename
 					// Video exists - serve it via static path
-					// Copy to static directory for serving
-					staticVideoPath := filepath.Join("srv/static/videos", videoFilename)
-					os.MkdirAll(filepath.Dir(staticVideoPath), 0755)
-					if videoData, err := os.ReadFile(videoPath); err == nil {
-						os.WriteFile(staticVideoPath, videoData, 0644)
-						sceneMap["videoUrl"] = fmt.Sprintf("/static/videos/%s", videoFilename)
+					videoURL, err := s.publishToStatic(videoPath, "videos/"+videoFilename)
+					if err != nil {
+						writeJSONError(w, http.StatusInternalServerError, "Failed to publish scene video: "+err.Error())
+						return
 					}
+					sceneMap["videoUrl"] = videoURL
 				}
+
+				// Projects saved before status tracking get one derived from their media
+				current, _ := sceneMap["status"].(string)
+				sceneMap["status"] = sceneStatusFor(SceneStatus(current), hasImage, hasVideo)
 				
 				scenes[i] = sceneMap
 			}
@@ -966,51 +3506,112 @@ func (s *Server) HandleLoadProject(w http.ResponseWriter, r *http.Request) {
 			slog.Info("loaded videoedit.vproj", "path", vprojPath)
 		}
 	}
-	
+
+	// Inlined media makes this response large; sending its length lets the
+	// client show load progress
+	body, err := json.Marshal(project)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to encode project: "+err.Error())
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(project)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
+
+// mediaSize is the pixel dimensions of a scene image or video, stored in
+// project.json so the editor and render pipeline don't have to re-probe.
+type mediaSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// probeImageSize reads just the image header to get its dimensions.
+func probeImageSize(path string) (mediaSize, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return mediaSize{}, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return mediaSize{}, err
+	}
+	return mediaSize{Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// sceneImageSize returns the dimensions of a scene image held in a data URL
+// or under /static/, or nil for remote or unreadable images rather than
+// fetching them.
+func (s *Server) sceneImageSize(imageURL string) *mediaSize {
+	var size mediaSize
+	var err error
+	switch {
+	case strings.HasPrefix(imageURL, "data:"):
+		var data []byte
+		if _, data, err = decodeDataURL(imageURL); err == nil {
+			var cfg image.Config
+			if cfg, _, err = image.DecodeConfig(bytes.NewReader(data)); err == nil {
+				size = mediaSize{Width: cfg.Width, Height: cfg.Height}
+			}
+		}
+	case strings.HasPrefix(imageURL, "/static/"):
+		imgPath, _ := s.staticFilePath(imageURL)
+		size, err = probeImageSize(imgPath)
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	return &size
 }
 
 // detectMimeType returns the MIME type based on file extension
 func detectMimeType(path string) string {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".png":
-		return "image/png"
-	case ".jpg", ".jpeg":
-		return "image/jpeg"
-	case ".gif":
-		return "image/gif"
-	case ".webp":
-		return "image/webp"
-	default:
-		return "image/png"
+	if mimeType, ok := imageMimeTypes[strings.ToLower(filepath.Ext(path))]; ok {
+		return mimeType
 	}
+	return "image/png"
 }
 
-// HandleBrowseFolders returns a list of folders for the file browser
+// HandleBrowseFolders returns a list of folders under ProjectsRoot for the
+// file browser; paths outside the root are refused with 403.
 func (s *Server) HandleBrowseFolders(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Query().Get("path")
-	if path == "" {
-		path = "/home/exedev/video-maker/projects"
+	// Browsing is confined to ProjectsRoot, which is also the default
+	rootPath, err := resolveProjectPath(s.ProjectsRoot, s.ProjectsRoot)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error accessing projects root: "+err.Error())
+		return
+	}
+	if err := os.MkdirAll(rootPath, 0755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error creating projects root: "+err.Error())
+		return
+	}
+	path := rootPath
+	if userPath := r.URL.Query().Get("path"); userPath != "" {
+		path, err = resolveProjectPath(s.ProjectsRoot, userPath)
+		if err != nil {
+			writeJSONError(w, http.StatusForbidden, "Path is outside the projects root")
+			return
+		}
 	}
-
-	// Clean and validate path
-	path = filepath.Clean(path)
 	
 	// Check if path exists
 	info, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			// Return parent directory if path doesn't exist
+			// Return parent directory if path doesn't exist; the parent of a
+			// path inside the root is the root at the highest
 			path = filepath.Dir(path)
 			info, err = os.Stat(path)
 			if err != nil {
-				http.Error(w, "Path not found", http.StatusNotFound)
+				writeJSONError(w, http.StatusNotFound, "Path not found")
 				return
 			}
 		} else {
-			http.Error(w, "Error accessing path: "+err.Error(), http.StatusInternalServerError)
+			writeJSONError(w, http.StatusInternalServerError, "Error accessing path: "+err.Error())
 			return
 		}
 	}
@@ -1023,21 +3624,47 @@ func (s *Server) HandleBrowseFolders(w http.ResponseWriter, r *http.Request) {
 	// Read directory contents
 	entries, err := os.ReadDir(path)
 	if err != nil {
-		http.Error(w, "Error reading directory: "+err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, "Error reading directory: "+err.Error())
+		return
+	}
+
+	limit, offset, ok := pageParams(w, r, defaultBrowseLimit, maxBrowseLimit)
+	if !ok {
 		return
 	}
 
 	type FolderEntry struct {
-		Name    string `json:"name"`
-		Path    string `json:"path"`
-		IsDir   bool   `json:"isDir"`
-		HasProjectJSON bool `json:"hasProjectJson"`
+		Name           string       `json:"name"`
+		Path           string       `json:"path"`
+		IsDir          bool         `json:"isDir"`
+		HasProjectJSON bool         `json:"hasProjectJson"`
+		// HasVproj marks folders with a videoedit.vproj from the editor
+		HasVproj       bool         `json:"hasVproj"`
+		Modified       *time.Time   `json:"modified,omitempty"`
+		Meta           *ProjectMeta `json:"meta,omitempty"`
+	}
+
+	withMeta := r.URL.Query().Get("withMeta") == "true"
+
+	// Only directories are listed, and hidden ones are skipped; the page is
+	// cut before any per-folder stat so huge directories stay cheap
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
 	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a), strings.ToLower(b)), cmp.Compare(a, b))
+	})
+	total := len(names)
+	names = names[min(offset, total):min(offset+limit, total)]
 
 	folders := []FolderEntry{}
 	
-	// Add parent directory option (unless at root)
-	if path != "/" {
+	// The parent directory always comes first on every page, except at the
+	// projects root so browsing can't climb out of it
+	if path != rootPath {
 		parentPath := filepath.Dir(path)
 		folders = append(folders, FolderEntry{
 			Name:  "..",
@@ -1046,29 +3673,36 @@ func (s *Server) HandleBrowseFolders(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue // Only show directories
-		}
-		
-		// Skip hidden directories
-		if strings.HasPrefix(entry.Name(), ".") {
-			continue
-		}
-
-		entryPath := filepath.Join(path, entry.Name())
+	for _, name := range names {
+		entryPath := filepath.Join(path, name)
 		
 		// Check if this folder has a project.json
 		hasProjectJSON := false
 		if _, err := os.Stat(filepath.Join(entryPath, "project.json")); err == nil {
 			hasProjectJSON = true
 		}
+		_, err := os.Stat(filepath.Join(entryPath, "videoedit.vproj"))
+		hasVproj := err == nil
+
+		var modified *time.Time
+		if info, err := os.Stat(entryPath); err == nil {
+			modTime := info.ModTime()
+			modified = &modTime
+		}
+
+		var meta *ProjectMeta
+		if withMeta && hasProjectJSON {
+			meta = readProjectMeta(entryPath)
+		}
 
 		folders = append(folders, FolderEntry{
-			Name:           entry.Name(),
+			Name:           name,
 			Path:           entryPath,
 			IsDir:          true,
 			HasProjectJSON: hasProjectJSON,
+			HasVproj:       hasVproj,
+			Modified:       modified,
+			Meta:           meta,
 		})
 	}
 
@@ -1076,93 +3710,177 @@ func (s *Server) HandleBrowseFolders(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]any{
 		"currentPath": path,
 		"folders":     folders,
+		"total":       total,
+		"offset":      offset,
+		"limit":       limit,
 	})
 }
 
-func saveBase64Image(dataURL, filepath string) error {
-	// Parse data URL: data:image/png;base64,xxxxx
-	parts := strings.SplitN(dataURL, ",", 2)
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid data URL format")
+// defaultBrowseLimit and maxBrowseLimit bound how many folders one
+// browse-folders page lists.
+const (
+	defaultBrowseLimit = 200
+	maxBrowseLimit     = 1000
+)
+
+// pageParams reads the ?limit and ?offset query params, defaulting limit to
+// def and capping it at maxLimit. On a malformed value it writes a 400 and
+// returns false.
+func pageParams(w http.ResponseWriter, r *http.Request, def, maxLimit int) (limit, offset int, ok bool) {
+	limit = def
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return 0, 0, false
+		}
+		limit = min(n, maxLimit)
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+			return 0, 0, false
+		}
+		offset = n
 	}
+	return limit, offset, true
+}
 
-	// Decode base64
-	imageData, err := base64.StdEncoding.DecodeString(parts[1])
+// ProjectMeta is a peek at a saved project for the folder browser.
+type ProjectMeta struct {
+	Title      string `json:"title"`
+	SceneCount int    `json:"sceneCount"`
+	SavedAt    string `json:"savedAt,omitempty"`
+}
+
+// maxProjectMetaBytes bounds how much of a project.json the folder browser
+// reads; media lives in separate files, so real projects fit well within it.
+const maxProjectMetaBytes = 1 << 20
+
+// readProjectMeta reads the title and scene count from a project folder's
+// project.json. Scenes are decoded as empty structs so only their count is
+// kept. It returns nil for unreadable, oversized or unrecognised files.
+func readProjectMeta(dir string) *ProjectMeta {
+	f, err := os.Open(filepath.Join(dir, "project.json"))
 	if err != nil {
-		return fmt.Errorf("failed to decode base64: %w", err)
+		return nil
+	}
+	defer f.Close()
+
+	var project struct {
+		StoryPrompt string     `json:"storyPrompt"`
+		Scenes      []struct{} `json:"scenes"`
+		SavedAt     string     `json:"savedAt"`
+		Settings    struct {
+			Title string `json:"title"`
+		} `json:"settings"`
+	}
+	if err := json.NewDecoder(io.LimitReader(f, maxProjectMetaBytes)).Decode(&project); err != nil {
+		return nil
 	}
 
-	// Write to file
-	if err := os.WriteFile(filepath, imageData, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	title := project.Settings.Title
+	if title == "" {
+		title = truncate(project.StoryPrompt, 80)
 	}
+	return &ProjectMeta{
+		Title:      title,
+		SceneCount: len(project.Scenes),
+		SavedAt:    project.SavedAt,
+	}
+}
 
-	return nil
+// base64Encodings are tried in turn on data URL payloads: canvas exports and
+// some browsers produce URL-safe or unpadded base64.
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding,
+	base64.URLEncoding,
+	base64.RawStdEncoding,
+	base64.RawURLEncoding,
 }
 
-func saveBase64Video(dataURL, filepath string) error {
-	// Parse data URL: data:video/mp4;base64,xxxxx
-	parts := strings.SplitN(dataURL, ",", 2)
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid data URL format")
+// decodeDataURL splits a base64 data URL into its media type and payload.
+// Whitespace in the payload (line-wrapped base64) is ignored. Errors name the
+// part of the URL that is malformed.
+func decodeDataURL(dataURL string) (string, []byte, error) {
+	// Parse data URL: data:image/png;base64,xxxxx
+	if !strings.HasPrefix(dataURL, "data:") {
+		return "", nil, errors.New(`invalid data URL: must start with "data:"`)
+	}
+	header, payload, ok := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+	if !ok {
+		return "", nil, errors.New("invalid data URL: no comma between the header and the data")
+	}
+	params := strings.Split(header, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+	if !slices.Contains(params[1:], "base64") {
+		return "", nil, fmt.Errorf("invalid data URL header %q: data must be base64-encoded", header)
 	}
 
 	// Decode base64
-	videoData, err := base64.StdEncoding.DecodeString(parts[1])
+	payload = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, payload)
+	if payload == "" {
+		return "", nil, errors.New("invalid data URL: the data is empty")
+	}
+	var err error
+	for _, enc := range base64Encodings {
+		var data []byte
+		if data, err = enc.DecodeString(payload); err == nil {
+			return mediaType, data, nil
+		}
+	}
+	return "", nil, fmt.Errorf("invalid data URL: the data is not valid base64: %w", err)
+}
+
+// saveBase64Image writes an image data URL next to destPath, with the
+// extension of the URL's media type (scene_1.png becomes scene_1.webp for a
+// WebP image), and returns the path written. Copies of the image in other
+// formats are removed so lookups by name find the new one.
+func saveBase64Image(dataURL, destPath string) (string, error) {
+	mediaType, imageData, err := decodeDataURL(dataURL)
 	if err != nil {
-		return fmt.Errorf("failed to decode base64: %w", err)
+		return "", err
+	}
+	if !strings.HasPrefix(mediaType, "image/") {
+		return "", fmt.Errorf("invalid data URL media type %q: expected an image", mediaType)
 	}
 
-	// Write to file
-	if err := os.WriteFile(filepath, videoData, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	stem := strings.TrimSuffix(destPath, filepath.Ext(destPath))
+	path := stem + imageExtension(dataURL)
+	if err := os.WriteFile(path, imageData, 0644); err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	for _, ext := range imageExtensions {
+		if stem+ext != path {
+			os.Remove(stem + ext)
+		}
 	}
 
-	return nil
+	return path, nil
 }
 
-func downloadVideo(videoURL, destPath, staticDir string) error {
-	var srcPath string
-	
-	if strings.HasPrefix(videoURL, "/static/videos/") {
-		// Local static file
-		srcPath = filepath.Join(staticDir, strings.TrimPrefix(videoURL, "/static/"))
-	} else if strings.HasPrefix(videoURL, "http") {
-		// Download from remote URL
-		resp, err := http.Get(videoURL)
-		if err != nil {
-			return fmt.Errorf("failed to download video: %w", err)
-		}
-		defer resp.Body.Close()
-		
-		out, err := os.Create(destPath)
-		if err != nil {
-			return fmt.Errorf("failed to create video file: %w", err)
-		}
-		defer out.Close()
-		
-		_, err = io.Copy(out, resp.Body)
-		return err
-	} else {
-		return fmt.Errorf("unsupported video URL format")
-	}
-	
-	// Copy local file
-	input, err := os.ReadFile(srcPath)
+func saveBase64Video(dataURL, filepath string) error {
+	// Parse data URL: data:video/mp4;base64,xxxxx
+	_, videoData, err := decodeDataURL(dataURL)
 	if err != nil {
-		return fmt.Errorf("failed to read source video: %w", err)
+		return err
 	}
-	
-	if err := os.WriteFile(destPath, input, 0644); err != nil {
-		return fmt.Errorf("failed to write video file: %w", err)
+
+	// Write to file
+	if _, err := writeFileAtomic(filepath, bytes.NewReader(videoData), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
 	}
-	
+
 	return nil
 }
 
 func generateScenesWithCharacters(keyframes []Keyframe, storyPrompt string, characters []Character, artImages []ArtImages) []Scene {
-	colors := []string{"1a1a2e", "16213e", "0f3460", "533483", "e94560", "2d4059", "3d5a80", "5c4d7d"}
-	
 	// Build character art lookup map
 	artMap := make(map[int]string)
 	for _, art := range artImages {
@@ -1179,20 +3897,19 @@ func generateScenesWithCharacters(keyframes []Keyframe, storyPrompt string, char
 	if len(keyframes) > 0 {
 		scenes := make([]Scene, len(keyframes))
 		for i, kf := range keyframes {
-			colorIdx := i % len(colors)
-			
 			// Build image prompt that includes character references
-			imagePrompt := buildScenePrompt(kf.Description, characters, artImages)
+			refs := characterReferences(characters, artImages, kf.CharacterWeights)
+			imagePrompt := buildScenePrompt(kf.Description, characters, artImages, refs)
 			
-			// TODO: In production, this would call the image generation API
-			// with the character art images as reference for consistency
-			// For now, use placeholder
 			scenes[i] = Scene{
-				ID:          fmt.Sprintf("scene_%d", i+1),
-				Narration:   kf.Description,
-				ImagePrompt: imagePrompt,
-				ImageURL:    fmt.Sprintf("https://placehold.co/512x288/%s/ffffff?text=Scene+%d", colors[colorIdx], i+1),
+				ID:               fmt.Sprintf("scene_%d", i+1),
+				Narration:        kf.Description,
+				ImagePrompt:      imagePrompt,
+				ImageURL:         placeholderImage(GenOptions{References: refs, Scene: i + 1}),
+				CharacterWeights: kf.CharacterWeights,
+				Speaker:          kf.Speaker,
 			}
+			scenes[i].Status = sceneStatusFor(SceneDraft, scenes[i].ImageURL != "", false)
 		}
 		return scenes
 	}
@@ -1211,26 +3928,39 @@ func generateScenesWithCharacters(keyframes []Keyframe, storyPrompt string, char
 	
 	scenes := make([]Scene, len(defaultScenes))
 	for i, ds := range defaultScenes {
-		colorIdx := i % len(colors)
-		imagePrompt := buildScenePrompt(ds.prompt, characters, artImages)
+		refs := characterReferences(characters, artImages, nil)
+		imagePrompt := buildScenePrompt(ds.prompt, characters, artImages, refs)
 		scenes[i] = Scene{
 			ID:          fmt.Sprintf("scene_%d", i+1),
 			Narration:   ds.narration,
 			ImagePrompt: imagePrompt,
-			ImageURL:    fmt.Sprintf("https://placehold.co/512x288/%s/ffffff?text=Scene+%d", colors[colorIdx], i+1),
+			ImageURL:    placeholderImage(GenOptions{References: refs, Scene: i + 1}),
 		}
+		scenes[i].Status = sceneStatusFor(SceneDraft, scenes[i].ImageURL != "", false)
 	}
 	return scenes
 }
 
-// buildScenePrompt creates a detailed prompt that references character art for consistency
-func buildScenePrompt(sceneDescription string, characters []Character, artImages []ArtImages) string {
+// buildScenePrompt creates a detailed prompt that references character art for consistency.
+// When the references aren't equally weighted, each character's share is noted
+// so text-only providers still know who the scene focuses on.
+func buildScenePrompt(sceneDescription string, characters []Character, artImages []ArtImages, refs []CharacterRef) string {
 	prompt := sceneDescription
 	
+	weighted := false
+	for _, ref := range refs {
+		if math.Abs(ref.Weight-refs[0].Weight) > 1e-9 {
+			weighted = true
+		}
+	}
+	
 	if len(characters) > 0 {
 		prompt += "\n\nCharacters in scene (use reference images for consistency):"
-		for _, char := range characters {
+		for i, char := range characters {
 			prompt += fmt.Sprintf("\n- %s", char.Description)
+			if weighted && i < len(refs) {
+				prompt += fmt.Sprintf(" (emphasis %.0f%%)", refs[i].Weight*100)
+			}
 		}
 	}
 	
@@ -1283,8 +4013,7 @@ type GitHubTestRequest struct {
 
 func (s *Server) HandleGitHubTest(w http.ResponseWriter, r *http.Request) {
 	var req GitHubTestRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req, maxJSONBody) {
 		return
 	}
 
@@ -1298,12 +4027,11 @@ func (s *Server) HandleGitHubTest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Test GitHub API connection
-	client := &http.Client{Timeout: 10 * time.Second}
-	apiReq, _ := http.NewRequest("GET", "https://api.github.com/user", nil)
-	apiReq.Header.Set("Authorization", "token "+req.Token)
-	apiReq.Header.Set("Accept", "application/vnd.github.v3+json")
+	ctx, cancel := context.WithTimeout(r.Context(), apiCheckTimeout)
+	defer cancel()
+	apiReq, _ := newGitHubRequest(ctx, "GET", githubAPIURL+"/user", req.Token, nil)
 
-	resp, err := client.Do(apiReq)
+	resp, err := s.httpClient.Do(apiReq)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]any{
@@ -1333,18 +4061,180 @@ func (s *Server) HandleGitHubTest(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// githubAPIURL is the GitHub REST API root.
+var githubAPIURL = "https://api.github.com"
+
+// newGitHubRequest builds a GitHub API request authenticated with token.
+func newGitHubRequest(ctx context.Context, method, endpoint, token string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	return req, nil
+}
+
+// GitHubRepo is one of the user's repositories, as listed for the push picker.
+type GitHubRepo struct {
+	Name          string `json:"name"`
+	FullName      string `json:"fullName"`
+	Private       bool   `json:"private"`
+	DefaultBranch string `json:"defaultBranch"`
+}
+
+// linkNext matches the rel="next" target of a GitHub Link header.
+var linkNext = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// errMissingRepoScope means the token can't see the user's repositories.
+var errMissingRepoScope = errors.New("the token lacks the repo scope; create one with repo access")
+
+// listGitHubRepos fetches every repository the token's user can access,
+// following the Link header's next pages.
+func (s *Server) listGitHubRepos(ctx context.Context, token string) ([]GitHubRepo, error) {
+	repos := []GitHubRepo{}
+	next := githubAPIURL + "/user/repos?per_page=100"
+	for next != "" {
+		req, err := newGitHubRequest(ctx, "GET", next, token, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		// Classic tokens list their scopes; fine-grained tokens send none
+		scopes, classic := resp.Header["X-Oauth-Scopes"]
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized:
+			resp.Body.Close()
+			return nil, fmt.Errorf("GitHub authentication failed (status %d)", resp.StatusCode)
+		case resp.StatusCode == http.StatusForbidden,
+			resp.StatusCode == http.StatusOK && classic && !hasRepoScope(strings.Join(scopes, ",")):
+			resp.Body.Close()
+			return nil, errMissingRepoScope
+		case resp.StatusCode != http.StatusOK:
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			return nil, fmt.Errorf("GitHub returned status %d: %s", resp.StatusCode, body)
+		}
+
+		var page []struct {
+			Name          string `json:"name"`
+			FullName      string `json:"full_name"`
+			Private       bool   `json:"private"`
+			DefaultBranch string `json:"default_branch"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse repository list: %w", err)
+		}
+		for _, repo := range page {
+			repos = append(repos, GitHubRepo(repo))
+		}
+
+		next = ""
+		if match := linkNext.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+			next = match[1]
+		}
+	}
+	return repos, nil
+}
+
+// hasRepoScope reports whether a comma-separated X-OAuth-Scopes list grants
+// full repo access.
+func hasRepoScope(scopes string) bool {
+	for _, scope := range strings.Split(scopes, ",") {
+		if strings.TrimSpace(scope) == "repo" {
+			return true
+		}
+	}
+	return false
+}
+
+// githubDefaultBranch returns the default branch of owner/repo, or "" if the
+// repo doesn't exist.
+func (s *Server) githubDefaultBranch(ctx context.Context, token, owner, repo string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, apiCheckTimeout)
+	defer cancel()
+	req, err := newGitHubRequest(ctx, "GET", fmt.Sprintf("%s/repos/%s/%s", githubAPIURL, url.PathEscape(owner), url.PathEscape(repo)), token, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", fmt.Errorf("GitHub returned status %d", resp.StatusCode)
+	}
+	var info struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("failed to parse repository: %w", err)
+	}
+	return info.DefaultBranch, nil
+}
+
+// HandleGitHubRepos lists the user's repositories so the push form can offer
+// them instead of a free-typed name.
+func (s *Server) HandleGitHubRepos(w http.ResponseWriter, r *http.Request) {
+	var req GitHubTestRequest
+	if !decodeJSON(w, r, &req, maxJSONBody) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.Username == "" || req.Token == "" {
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false,
+			"error":   "Username and token are required",
+		})
+		return
+	}
+
+	repos, err := s.listGitHubRepos(r.Context(), req.Token)
+	if err != nil {
+		msg := redactSecret(err.Error(), req.Token)
+		slog.Warn("failed to list GitHub repos", "user", req.Username, "error", msg)
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false,
+			"error":   "Failed to list repositories: " + msg,
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"repos":   repos,
+	})
+}
+
 type GitHubPushRequest struct {
-	Username   string `json:"username"`
-	Token      string `json:"token"`
-	Repo       string `json:"repo"`
-	Branch     string `json:"branch"`
-	CreateRepo bool   `json:"createRepo"`
+	Username    string `json:"username"`
+	Token       string `json:"token"`
+	Repo        string `json:"repo"`
+	Branch      string `json:"branch"`
+	CreateRepo  bool   `json:"createRepo"`
+	// Force overwrites the remote branch even if it has commits the
+	// source doesn't; otherwise a non-fast-forward push is refused
+	Force       bool   `json:"force"`
+	// ProjectPath pushes just that project folder (under ProjectsRoot) as
+	// its own repository instead of the app's source
+	ProjectPath string `json:"projectPath"`
 }
 
 func (s *Server) HandleGitHubPush(w http.ResponseWriter, r *http.Request) {
 	var req GitHubPushRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req, maxJSONBody) {
 		return
 	}
 
@@ -1357,82 +4247,253 @@ func (s *Server) HandleGitHubPush(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Branch == "" {
-		req.Branch = "main"
-	}
-
-	// Source code path
-	sourcePath := "/home/exedev/video-maker"
-	repoURL := fmt.Sprintf("https://%s:%s@github.com/%s/%s.git", req.Username, req.Token, req.Username, req.Repo)
-	publicRepoURL := fmt.Sprintf("https://github.com/%s/%s", req.Username, req.Repo)
-
-	// Create repository if requested
-	if req.CreateRepo {
-		if err := createGitHubRepo(req.Username, req.Token, req.Repo); err != nil {
-			slog.Warn("failed to create repo (may already exist)", "error", err)
-			// Continue anyway - repo might already exist
+	sourcePath := s.SourcePath
+	push := pushRepo
+	commitMsg := fmt.Sprintf("Update video-maker source code - %s", time.Now().Format("2006-01-02 15:04:05"))
+	if req.ProjectPath != "" {
+		projectPath, err := resolveProjectPath(s.ProjectsRoot, req.ProjectPath)
+		if err == nil {
+			_, err = os.Stat(projectPath)
 		}
-	}
-
-	// Configure git user if not set
-	exec.Command("git", "-C", sourcePath, "config", "user.email", "developer@video-maker.local").Run()
-	exec.Command("git", "-C", sourcePath, "config", "user.name", "Video Maker Developer").Run()
-
-	// Check if remote exists, update or add it
-	checkRemote := exec.Command("git", "-C", sourcePath, "remote", "get-url", "origin")
-	if err := checkRemote.Run(); err != nil {
-		// Remote doesn't exist, add it
-		if err := exec.Command("git", "-C", sourcePath, "remote", "add", "origin", repoURL).Run(); err != nil {
+		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]any{
 				"success": false,
-				"error":   "Failed to add git remote: " + err.Error(),
+				"error":   "Invalid project path: " + err.Error(),
 			})
 			return
 		}
-	} else {
-		// Update existing remote
-		exec.Command("git", "-C", sourcePath, "remote", "set-url", "origin", repoURL).Run()
+		sourcePath, push = projectPath, pushProject
+		commitMsg = fmt.Sprintf("Back up project %s - %s", filepath.Base(projectPath), time.Now().Format("2006-01-02 15:04:05"))
+	} else if err := checkGitRepo(sourcePath); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"success": false,
+			"error":   "Source path is not usable: " + err.Error(),
+		})
+		return
 	}
-
-	// Stage all changes
-	addCmd := exec.Command("git", "-C", sourcePath, "add", "-A")
-	if output, err := addCmd.CombinedOutput(); err != nil {
-		slog.Warn("git add warning", "output", string(output))
+	// The token travels in a per-command Authorization header rather than
+	// the remote URL, so it never lands in .git/config or git's output
+	repoURL := fmt.Sprintf("%s/%s/%s.git", githubURL, req.Username, req.Repo)
+	publicRepoURL := fmt.Sprintf("%s/%s/%s", githubURL, req.Username, req.Repo)
+	auth := gitAuthHeader(req.Username, req.Token)
+
+	// Without a branch, push to the repo's own default; only a repo that
+	// doesn't exist yet starts out on main
+	if req.Branch == "" {
+		branch, err := s.githubDefaultBranch(r.Context(), req.Token, req.Username, req.Repo)
+		if err != nil {
+			msg := redactSecret(err.Error(), req.Token)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"success": false,
+				"error":   "Failed to look up the default branch: " + msg,
+			})
+			return
+		}
+		if branch == "" {
+			branch = "main"
+		}
+		req.Branch = branch
 	}
 
-	// Commit (if there are changes)
-	commitMsg := fmt.Sprintf("Update video-maker source code - %s", time.Now().Format("2006-01-02 15:04:05"))
-	commitCmd := exec.Command("git", "-C", sourcePath, "commit", "-m", commitMsg, "--allow-empty")
-	if output, err := commitCmd.CombinedOutput(); err != nil {
-		slog.Info("git commit", "output", string(output))
+	// Create repository if requested
+	if req.CreateRepo {
+		if err := s.createGitHubRepo(r.Context(), req.Username, req.Token, req.Repo); err != nil {
+			slog.Warn("failed to create repo (may already exist)", "error", redactSecret(err.Error(), req.Token))
+			// Continue anyway - repo might already exist
+		}
 	}
 
-	// Push to GitHub
-	pushCmd := exec.Command("git", "-C", sourcePath, "push", "-u", "origin", req.Branch, "--force")
-	output, err := pushCmd.CombinedOutput()
+	sha, err := push(sourcePath, repoURL, auth, req.Branch, commitMsg, req.Force)
 	if err != nil {
-		slog.Error("git push failed", "error", err, "output", string(output))
+		msg := redactSecret(err.Error(), req.Token)
+		slog.Error("git push failed", "error", msg)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]any{
 			"success": false,
-			"error":   "Failed to push: " + string(output),
+			"error":   "Failed to push: " + msg,
 		})
 		return
 	}
 
-	slog.Info("successfully pushed to GitHub", "repo", publicRepoURL)
+	slog.Info("successfully pushed to GitHub", "repo", publicRepoURL, "commit", sha, "force", req.Force)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{
 		"success": true,
 		"repoUrl": publicRepoURL,
 		"branch":  req.Branch,
+		"commit":  sha,
 	})
 }
 
-func createGitHubRepo(username, token, repoName string) error {
-	client := &http.Client{Timeout: 10 * time.Second}
+// githubURL is where pushed repositories live.
+var githubURL = "https://github.com"
+
+// gitAuthHeader is an http.extraHeader value authenticating git over HTTPS
+// with a GitHub username and token.
+func gitAuthHeader(username, token string) string {
+	return "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+token))
+}
+
+// urlCredentials matches the user:password part of a URL.
+var urlCredentials = regexp.MustCompile(`(\w+://)[^/@\s]+@`)
+
+// redactSecret scrubs URL credentials and any occurrence of secret from
+// command output or errors before they are logged or returned.
+func redactSecret(text, secret string) string {
+	text = urlCredentials.ReplaceAllString(text, "${1}***@")
+	if secret != "" {
+		text = strings.ReplaceAll(text, secret, "***")
+	}
+	return text
+}
+
+// errNonFastForward is returned when the remote branch has commits the
+// source doesn't, and force wasn't requested.
+var errNonFastForward = errors.New("the remote branch has commits that aren't in the source; pull them first or push with force")
+
+// checkGitRepo verifies that path is an existing git work tree.
+func checkGitRepo(path string) error {
+	if path == "" {
+		return errors.New("no source path is configured")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	if out, err := runGit(path, "rev-parse", "--is-inside-work-tree"); err != nil || out != "true" {
+		return fmt.Errorf("%s is not a git repository", path)
+	}
+	return nil
+}
+
+// runGit runs git in dir and returns its trimmed combined output.
+func runGit(dir string, args ...string) (string, error) {
+	return runGitAuth(dir, "", args...)
+}
+
+// runGitAuth is runGit sending authHeader (see gitAuthHeader) with HTTP
+// requests. The header is passed through the environment, so it isn't
+// saved in the repo config or visible in the process list.
+func runGitAuth(dir, authHeader string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if authHeader != "" {
+		cmd.Env = append(os.Environ(),
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0="+authHeader,
+		)
+	}
+	output, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
+
+// projectGitignore lists the bulky, regenerable files left out of project
+// backups, in .gitignore syntax.
+const projectGitignore = `# Frames downloaded for rendering; keyframes/ holds the originals
+videos/*.png
+# Writes in progress
+*.tmp
+`
+
+// pushProject pushes the project folder at projectPath like pushRepo, from
+// a repository in a temporary directory that uses the folder as its work
+// tree. Project folders often sit inside the app's own checkout, so a .git
+// in the folder would nest a repository there. The new commit goes on top
+// of what branch already holds, keeping the backups' history.
+func pushProject(projectPath, remoteURL, authHeader, branch, message string, force bool) (string, error) {
+	info, err := os.Stat(projectPath)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", projectPath)
+	}
+
+	repo, err := os.MkdirTemp("", tempDirPrefix+"-project-repo-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(repo)
+	if output, err := runGit(repo, "init", "-q"); err != nil {
+		return "", fmt.Errorf("git init: %v: %s", err, output)
+	}
+	if output, err := runGit(repo, "config", "core.worktree", projectPath); err != nil {
+		return "", fmt.Errorf("git config: %v: %s", err, output)
+	}
+	if err := os.WriteFile(filepath.Join(repo, ".git", "info", "exclude"), []byte(projectGitignore), 0644); err != nil {
+		return "", err
+	}
+	if output, err := runGit(repo, "remote", "add", "origin", remoteURL); err != nil {
+		return "", fmt.Errorf("add git remote: %v: %s", err, output)
+	}
+	// A branch that doesn't exist yet fails to fetch; the push creates it
+	if _, err := runGitAuth(repo, authHeader, "fetch", "-q", "origin", "refs/heads/"+branch); err == nil {
+		if output, err := runGit(repo, "reset", "-q", "--soft", "FETCH_HEAD"); err != nil {
+			return "", fmt.Errorf("git reset: %v: %s", err, output)
+		}
+	}
+	return pushRepo(repo, remoteURL, authHeader, branch, message, force)
+}
+
+// pushRepo commits everything in the checkout at dir with message and
+// pushes HEAD to branch on remoteURL, authenticating with authHeader, and
+// returns the pushed commit's SHA. Without force a push that isn't a
+// fast-forward fails with errNonFastForward.
+func pushRepo(dir, remoteURL, authHeader, branch, message string, force bool) (string, error) {
+	sourcePath := dir
+	// Configure git user if not set
+	if _, err := runGit(sourcePath, "config", "user.email"); err != nil {
+		runGit(sourcePath, "config", "user.email", "developer@video-maker.local")
+	}
+	if _, err := runGit(sourcePath, "config", "user.name"); err != nil {
+		runGit(sourcePath, "config", "user.name", "Video Maker Developer")
+	}
+
+	// Check if remote exists, update or add it
+	if _, err := runGit(sourcePath, "remote", "get-url", "origin"); err != nil {
+		if output, err := runGit(sourcePath, "remote", "add", "origin", remoteURL); err != nil {
+			return "", fmt.Errorf("add git remote: %v: %s", err, output)
+		}
+	} else if output, err := runGit(sourcePath, "remote", "set-url", "origin", remoteURL); err != nil {
+		return "", fmt.Errorf("update git remote: %v: %s", err, output)
+	}
+
+	// Stage and commit all changes
+	if output, err := runGit(sourcePath, "add", "-A"); err != nil {
+		return "", fmt.Errorf("git add: %v: %s", err, output)
+	}
+	if output, err := runGit(sourcePath, "commit", "-m", message, "--allow-empty"); err != nil {
+		return "", fmt.Errorf("git commit: %v: %s", err, output)
+	}
+	sha, err := runGit(sourcePath, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse: %v: %s", err, sha)
+	}
+
+	args := []string{"push", "-u", "origin", "HEAD:refs/heads/" + branch}
+	if force {
+		args = append(args, "--force")
+	}
+	output, err := runGitAuth(sourcePath, authHeader, args...)
+	if err != nil {
+		if strings.Contains(output, "non-fast-forward") || strings.Contains(output, "[rejected]") {
+			return "", errNonFastForward
+		}
+		return "", fmt.Errorf("%v: %s", err, output)
+	}
+	return sha, nil
+}
+
+func (s *Server) createGitHubRepo(ctx context.Context, username, token, repoName string) error {
+	ctx, cancel := context.WithTimeout(ctx, apiCheckTimeout)
+	defer cancel()
 	
 	reqBody, _ := json.Marshal(map[string]any{
 		"name":        repoName,
@@ -1441,12 +4502,10 @@ func createGitHubRepo(username, token, repoName string) error {
 		"auto_init":   false,
 	})
 	
-	req, _ := http.NewRequest("POST", "https://api.github.com/user/repos", strings.NewReader(string(reqBody)))
-	req.Header.Set("Authorization", "token "+token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req, _ := newGitHubRequest(ctx, "POST", githubAPIURL+"/user/repos", token, strings.NewReader(string(reqBody)))
 	req.Header.Set("Content-Type", "application/json")
 	
-	resp, err := client.Do(req)
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -1460,34 +4519,109 @@ func createGitHubRepo(username, token, repoName string) error {
 	return nil
 }
 
+// unlessSafeMode wraps handlers that expose raw filesystem paths or git, or
+// move project folders, so they respond 403 when SafeMode is enabled.
+func (s *Server) unlessSafeMode(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.SafeMode {
+			writeJSONError(w, http.StatusForbidden, "This endpoint is disabled in safe mode")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// Serve listens on addr until SIGINT or SIGTERM, then shuts down gracefully
+// (see shutdown). A second signal exits immediately.
 func (s *Server) Serve(addr string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Restored here rather than in New so StaticDir is final when finished
+	// jobs' output files are checked
+	if err := s.restoreJobs(ctx); err != nil {
+		return fmt.Errorf("failed to restore jobs: %w", err)
+	}
+
+	httpServer := &http.Server{Addr: addr, Handler: s.accessLog(s.cors(s.unlessDraining(s.routes())))}
+	slog.Info("starting server", "addr", addr, "corsOrigins", s.CORSOrigins)
+	errc := make(chan error, 1)
+	go func() { errc <- httpServer.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+	return s.shutdown(httpServer)
+}
+
+// routes registers every page and API endpoint on a new mux.
+func (s *Server) routes() *http.ServeMux {
 	mux := http.NewServeMux()
 	
 	// Pages
 	mux.HandleFunc("GET /{$}", s.HandleHome)
 	mux.HandleFunc("GET /storyboard/{id}", s.HandleStoryboard)
 	
-	// API
-	mux.HandleFunc("POST /api/projects", s.HandleCreateProject)
+	// API. Endpoints that start image or video generation are rate
+	// limited per client; reads and static files are not.
+	mux.HandleFunc("GET /api/projects", s.HandleListProjects)
+	mux.HandleFunc("POST /api/projects", s.rateLimited(s.HandleCreateProject))
 	mux.HandleFunc("GET /api/projects/{id}", s.HandleGetProject)
-	mux.HandleFunc("POST /api/generate-art-images", s.HandleGenerateArtImages)
-	mux.HandleFunc("POST /api/generate-video-clips", s.HandleGenerateVideoClips)
-	mux.HandleFunc("POST /api/save-project", s.HandleSaveProject)
-	mux.HandleFunc("POST /api/save-editor-project", s.HandleSaveEditorProject)
-	mux.HandleFunc("POST /api/save-keyframe", s.HandleSaveKeyframe)
-	mux.HandleFunc("POST /api/generate-video", s.HandleGenerateVideo)
-	mux.HandleFunc("POST /api/save-video-clips", s.HandleSaveVideoClips)
+	mux.HandleFunc("DELETE /api/projects/{id}", s.HandleDeleteProject)
+	mux.HandleFunc("POST /api/projects/{id}/scenes", s.HandleInsertScene)
+	mux.HandleFunc("GET /api/projects/{id}/scenes/{scene}", s.HandleGetScene)
+	mux.HandleFunc("PATCH /api/projects/{id}/scenes/{scene}", s.HandleUpdateScene)
+	mux.HandleFunc("DELETE /api/projects/{id}/scenes/{scene}", s.HandleDeleteScene)
+	mux.HandleFunc("POST /api/projects/{id}/reorder", s.HandleReorderScenes)
+	mux.HandleFunc("POST /api/projects/{id}/scenes/{scene}/refine", s.rateLimited(s.HandleRefineScene))
+	mux.HandleFunc("POST /api/projects/{id}/scenes/{scene}/regenerate", s.rateLimited(s.HandleRegenerateScene))
+	mux.HandleFunc("GET /api/projects/{id}/keyframes/{file}", s.HandleProjectKeyframe)
+	mux.HandleFunc("GET /api/projects/{id}/video/{scene}", s.HandleProjectVideo)
+	mux.HandleFunc("POST /api/projects/{id}/regenerate-all", s.rateLimited(s.HandleRegenerateAllScenes))
+	mux.HandleFunc("POST /api/projects/{id}/restyle", s.rateLimited(s.HandleRestyleProject))
+	mux.HandleFunc("POST /api/projects/{id}/restyle/revert", s.HandleRevertStyle)
+	mux.HandleFunc("GET /api/projects/{id}/clips.zip", s.HandleExportClips)
+	mux.HandleFunc("GET /api/projects/{id}/export.zip", s.HandleExportProject)
+	mux.HandleFunc("GET /api/projects/{id}/render-plan", s.HandleRenderPlan)
+	mux.HandleFunc("POST /api/projects/{id}/render-final", s.rateLimited(s.HandleRenderFinal))
+	mux.HandleFunc("POST /api/projects/{id}/preview.gif", s.rateLimited(s.HandlePreview))
+	mux.HandleFunc("POST /api/projects/{id}/audio", s.HandleUploadAudioBed)
+	mux.HandleFunc("POST /api/projects/{id}/music", s.HandleUploadAudioBed)
+	mux.HandleFunc("GET /api/projects/{id}/generations", s.HandleListGenerations)
+	mux.HandleFunc("POST /api/projects/{id}/duplicate", s.HandleDuplicateProject)
+	mux.HandleFunc("GET /api/projects/{id}/ws", s.HandleProjectWebSocket)
+	mux.HandleFunc("GET /api/jobs/{jobId}", s.HandleGetJob)
+	mux.HandleFunc("GET /api/storage", s.HandleStorage)
+	mux.HandleFunc("GET /api/health", s.HandleHealth)
+	mux.HandleFunc("GET /api/templates", s.HandleListTemplates)
+	mux.HandleFunc("POST /api/templates", s.HandleSaveTemplate)
+	mux.HandleFunc("POST /api/generate-art-images", s.rateLimited(s.HandleGenerateArtImages))
+	mux.HandleFunc("POST /api/providers/{name}/test", s.rateLimited(s.HandleTestProvider))
+	mux.HandleFunc("POST /api/generate-video-clips", s.rateLimited(s.HandleGenerateVideoClips))
+	mux.HandleFunc("GET /api/video-clips/status/{jobId}", s.HandleVideoClipsStatus)
 	mux.HandleFunc("POST /api/upload-video", s.HandleUploadVideo)
-	mux.HandleFunc("GET /api/load-project", s.HandleLoadProject)
-	mux.HandleFunc("GET /api/browse-folders", s.HandleBrowseFolders)
+	mux.HandleFunc("POST /api/extract-keyframes", s.rateLimited(s.HandleExtractKeyframes))
+
+	// Raw filesystem path and project folder endpoints (disabled in safe mode)
+	mux.HandleFunc("POST /api/save-project", s.unlessSafeMode(s.HandleSaveProject))
+	mux.HandleFunc("POST /api/save-editor-project", s.unlessSafeMode(s.HandleSaveEditorProject))
+	mux.HandleFunc("POST /api/save-keyframe", s.unlessSafeMode(s.HandleSaveKeyframe))
+	mux.HandleFunc("POST /api/generate-video", s.unlessSafeMode(s.rateLimited(s.HandleGenerateVideo)))
+	mux.HandleFunc("GET /api/generate-video/status/{jobId}", s.unlessSafeMode(s.HandleGenerateVideoStatus))
+	mux.HandleFunc("GET /api/generate-video/events/{jobId}", s.unlessSafeMode(s.HandleGenerateVideoEvents))
+	mux.HandleFunc("POST /api/save-video-clips", s.unlessSafeMode(s.HandleSaveVideoClips))
+	mux.HandleFunc("GET /api/load-project", s.unlessSafeMode(s.HandleLoadProject))
+	mux.HandleFunc("GET /api/browse-folders", s.unlessSafeMode(s.HandleBrowseFolders))
+	mux.HandleFunc("POST /api/projects/{id}/move", s.unlessSafeMode(s.HandleMoveProject))
 	
-	// GitHub integration
-	mux.HandleFunc("POST /api/github/test", s.HandleGitHubTest)
-	mux.HandleFunc("POST /api/github/push", s.HandleGitHubPush)
+	// GitHub integration (disabled in safe mode)
+	mux.HandleFunc("POST /api/github/test", s.unlessSafeMode(s.HandleGitHubTest))
+	mux.HandleFunc("POST /api/github/repos", s.unlessSafeMode(s.HandleGitHubRepos))
+	mux.HandleFunc("POST /api/github/push", s.unlessSafeMode(s.HandleGitHubPush))
 
 	// Static files
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(s.StaticDir))))
-	
-	slog.Info("starting server", "addr", addr)
-	return http.ListenAndServe(addr, mux)
+	mux.Handle("/static/", http.StripPrefix("/static/", s.staticCacheHeaders(s.staticETags(http.FileServer(http.Dir(s.StaticDir))))))
+	return mux
 }
@@ -0,0 +1,88 @@
+package srv
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// defaultShutdownTimeout is how long a shutdown waits for in-flight requests
+// and jobs before stopping them.
+const defaultShutdownTimeout = 2 * time.Minute
+
+// jobStopGrace is how long stopped jobs get to return, so their deferred
+// cleanup removes half-written render files before the process exits.
+const jobStopGrace = 10 * time.Second
+
+// jobDrainPoll is how often a shutdown checks whether jobs have finished.
+const jobDrainPoll = 200 * time.Millisecond
+
+// unlessDraining refuses requests that could start new work once shutdown
+// has begun; reads still succeed so clients can collect finished results.
+func (s *Server) unlessDraining(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.draining.Load() && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Retry-After", "30")
+			writeJSONError(w, http.StatusServiceUnavailable, "Server is shutting down; try again shortly")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// drainJobs waits until no job is queued or running, or ctx ends.
+func (s *Server) drainJobs(ctx context.Context) error {
+	ticker := time.NewTicker(jobDrainPoll)
+	defer ticker.Stop()
+	for {
+		if s.unfinishedJobs() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// unfinishedJobs counts the jobs that are queued or running.
+func (s *Server) unfinishedJobs() int {
+	s.jobsMu.RLock()
+	defer s.jobsMu.RUnlock()
+	n := 0
+	for _, job := range s.jobs {
+		if job.Status == JobQueued || job.Status == JobRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// shutdown stops httpServer gracefully: new work is refused, in-flight
+// requests and jobs get until the ShutdownTimeout deadline, and jobs still
+// running then are cancelled, which kills their ffmpeg processes.
+func (s *Server) shutdown(httpServer *http.Server) error {
+	s.draining.Store(true)
+	slog.Info("shutting down", "timeout", s.ShutdownTimeout, "jobs", s.unfinishedJobs())
+	ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+
+	err := httpServer.Shutdown(ctx)
+	if err := s.drainJobs(ctx); err != nil {
+		slog.Warn("jobs still running at the shutdown deadline; stopping them", "jobs", s.unfinishedJobs())
+		s.cancelJobs()
+		graceCtx, cancel := context.WithTimeout(context.Background(), jobStopGrace)
+		defer cancel()
+		if err := s.drainJobs(graceCtx); err != nil {
+			slog.Error("jobs didn't stop in time", "jobs", s.unfinishedJobs())
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("requests still open at the shutdown deadline", "error", err)
+		return httpServer.Close()
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// stabilityEndpoint is the v1 text-to-image API for SD 1.6, the engine that
+// still accepts 512x512 output.
+const stabilityEndpoint = "https://api.stability.ai/v1/generation/stable-diffusion-v1-6/text-to-image"
+
+// StabilityProvider generates images with Stability AI's text-to-image API.
+// Images come back as base64, so results are PNG data URLs.
+type StabilityProvider struct {
+	APIKey string
+	// Endpoint overrides the Stability URL (for tests)
+	Endpoint string
+	Client   *http.Client
+}
+
+func (p *StabilityProvider) Name() string { return "stability" }
+
+func (p *StabilityProvider) Generate(ctx context.Context, prompt string, opts GenOptions) (string, error) {
+	if p.APIKey == "" {
+		return "", errors.New("STABILITY_API_KEY is not set")
+	}
+
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = stabilityEndpoint
+	}
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 120 * time.Second}
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"text_prompts": []map[string]any{{"text": prompt}},
+		"width":        512,
+		"height":       512,
+		"samples":      1,
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("stability request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Artifacts []struct {
+			Base64       string `json:"base64"`
+			FinishReason string `json:"finishReason"`
+		} `json:"artifacts"`
+		Name    string `json:"name"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 32<<20)).Decode(&result); err != nil {
+		return "", fmt.Errorf("stability response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Prompts rejected by the moderation filter come back as a 400
+		// with this name rather than as a filtered artifact
+		if result.Name == "invalid_prompts" {
+			return "", &ProviderError{Provider: "stability", Code: providerErrContentFiltered, Message: result.Message}
+		}
+		return "", fmt.Errorf("stability: %s (status %d)", result.Message, resp.StatusCode)
+	}
+	if len(result.Artifacts) == 0 {
+		return "", errors.New("stability: no image returned")
+	}
+
+	artifact := result.Artifacts[0]
+	switch artifact.FinishReason {
+	case "CONTENT_FILTERED":
+		return "", &ProviderError{Provider: "stability", Code: providerErrContentFiltered, Message: "The image was blocked by the provider's safety filter"}
+	case "ERROR":
+		return "", errors.New("stability: generation failed")
+	}
+	return "data:image/png;base64," + artifact.Base64, nil
+}
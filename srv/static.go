@@ -0,0 +1,254 @@
+package srv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// videoExtensions lists the scene video containers we accept, in lookup order.
+var videoExtensions = []string{".mp4", ".webm", ".mov"}
+
+// videoMimeTypes maps video containers to content types. Go's built-in table
+// lacks these, so without registering them the static server depends on the
+// host's mime.types and can label webm clips as mp4.
+var videoMimeTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+}
+
+// imageExtensions lists the keyframe and character art formats we accept, in
+// lookup order.
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".webp", ".avif", ".gif"}
+
+// imageMimeTypes maps image formats to content types; like videoMimeTypes,
+// they're registered so AVIF and WebP are labelled right on any host.
+var imageMimeTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+	".avif": "image/avif",
+	".gif":  "image/gif",
+}
+
+func init() {
+	for ext, mimeType := range videoMimeTypes {
+		mime.AddExtensionType(ext, mimeType)
+	}
+	for ext, mimeType := range imageMimeTypes {
+		mime.AddExtensionType(ext, mimeType)
+	}
+}
+
+// sniffVideoExtension picks the extension for an uploaded video from its
+// first bytes, reporting false if it isn't a container we accept.
+// DetectContentType knows mp4 and webm; QuickTime files are mp4-style boxes
+// whose ftyp major brand is "qt  ".
+func sniffVideoExtension(head []byte) (string, bool) {
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" && string(head[8:12]) == "qt  " {
+		return ".mov", true
+	}
+	contentType := http.DetectContentType(head)
+	for _, ext := range videoExtensions {
+		if videoMimeTypes[ext] == contentType {
+			return ext, true
+		}
+	}
+	return "", false
+}
+
+// videoExtension picks the file extension for a scene video from its data URL
+// media type or its URL path, defaulting to .mp4.
+func videoExtension(videoURL string) string {
+	if strings.HasPrefix(videoURL, "data:") {
+		mediaType := strings.TrimPrefix(strings.SplitN(videoURL, ";", 2)[0], "data:")
+		for _, ext := range videoExtensions {
+			if videoMimeTypes[ext] == mediaType {
+				return ext
+			}
+		}
+		return ".mp4"
+	}
+
+	if i := strings.IndexAny(videoURL, "?#"); i >= 0 {
+		videoURL = videoURL[:i]
+	}
+	ext := strings.ToLower(path.Ext(videoURL))
+	if _, ok := videoMimeTypes[ext]; ok {
+		return ext
+	}
+	return ".mp4"
+}
+
+// findSceneVideo returns the filename of the scene's video in videosDir,
+// whichever container it was saved as, or the .mp4 name if none exists.
+func findSceneVideo(videosDir string, sceneNum int) string {
+	for _, ext := range videoExtensions {
+		name := fmt.Sprintf("scene_%d%s", sceneNum, ext)
+		if _, err := os.Stat(filepath.Join(videosDir, name)); err == nil {
+			return name
+		}
+	}
+	return fmt.Sprintf("scene_%d.mp4", sceneNum)
+}
+
+// imageExtension picks the file extension for an image from its data URL
+// media type, defaulting to .png.
+func imageExtension(dataURL string) string {
+	mediaType := strings.TrimPrefix(strings.SplitN(dataURL, ";", 2)[0], "data:")
+	for _, ext := range imageExtensions {
+		if imageMimeTypes[ext] == strings.ToLower(mediaType) {
+			return ext
+		}
+	}
+	return ".png"
+}
+
+// findImageFile returns the filename of the image named stem (e.g. "scene_1")
+// in dir, whichever format it was saved as, or the .png name if none exists.
+func findImageFile(dir, stem string) string {
+	for _, ext := range imageExtensions {
+		if _, err := os.Stat(filepath.Join(dir, stem+ext)); err == nil {
+			return stem + ext
+		}
+	}
+	return stem + ".png"
+}
+
+// CacheRule sets the Cache-Control header for static files matching Pattern.
+// A pattern starting with "." matches by extension; a pattern containing "/"
+// is a path.Match glob against the path under /static/; anything else is a
+// glob against the file's base name.
+type CacheRule struct {
+	Pattern      string
+	CacheControl string
+}
+
+// defaultStaticCachePolicy caches the content-hashed editor bundle forever and
+// forces revalidation of scene videos, published project images and previews,
+// which are overwritten in place; their ETags make that revalidation cheap.
+var defaultStaticCachePolicy = []CacheRule{
+	{Pattern: "editor/assets/*", CacheControl: "public, max-age=31536000, immutable"},
+	{Pattern: "videos/*", CacheControl: "no-cache"},
+	{Pattern: "images/*/*", CacheControl: "no-cache"},
+	{Pattern: "previews/*", CacheControl: "no-cache"},
+	{Pattern: ".mp4", CacheControl: "no-cache"},
+	{Pattern: ".webm", CacheControl: "no-cache"},
+	{Pattern: ".mov", CacheControl: "no-cache"},
+	{Pattern: ".css", CacheControl: "public, max-age=300"},
+	{Pattern: ".js", CacheControl: "public, max-age=300"},
+}
+
+// match reports whether the rule applies to a path relative to the static dir.
+func (c CacheRule) match(rel string) bool {
+	switch {
+	case strings.HasPrefix(c.Pattern, "."):
+		return strings.EqualFold(path.Ext(rel), c.Pattern)
+	case strings.Contains(c.Pattern, "/"):
+		ok, _ := path.Match(c.Pattern, rel)
+		return ok
+	default:
+		ok, _ := path.Match(c.Pattern, path.Base(rel))
+		return ok
+	}
+}
+
+// staticCacheHeaders applies the first matching rule from StaticCachePolicy.
+// It expects the /static/ prefix to have been stripped already.
+func (s *Server) staticCacheHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rel := strings.TrimPrefix(r.URL.Path, "/")
+		for _, rule := range s.StaticCachePolicy {
+			if rule.match(rel) {
+				w.Header().Set("Cache-Control", rule.CacheControl)
+				break
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// staticETags gives static files an ETag made from their size and
+// modification time. The file server then answers a matching If-None-Match
+// with 304, so the no-cache rules above cost a round trip, not a download.
+// It expects the /static/ prefix to have been stripped already.
+func (s *Server) staticETags(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if filePath, ok := s.staticFilePath("/static/" + strings.TrimPrefix(r.URL.Path, "/")); ok {
+			if info, err := os.Stat(filePath); err == nil && info.Mode().IsRegular() {
+				w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// projectStaticDir is where a project's images are published in the static
+// dir: images/ and a short hash of the project's path, so two projects'
+// scene_1.png don't overwrite each other.
+func projectStaticDir(projectPath string) string {
+	sum := sha256.Sum256([]byte(projectPath))
+	return "images/" + hex.EncodeToString(sum[:6])
+}
+
+// staticFilePath maps a /static/ URL to its file in the static dir, without
+// letting ".." climb out of it.
+func (s *Server) staticFilePath(staticURL string) (string, bool) {
+	rel, ok := strings.CutPrefix(staticURL, "/static/")
+	if !ok {
+		return "", false
+	}
+	if i := strings.IndexAny(rel, "?#"); i >= 0 {
+		rel = rel[:i]
+	}
+	return filepath.Join(s.StaticDir, filepath.FromSlash(path.Clean("/"+rel))), true
+}
+
+// publishToStatic copies srcPath into the static dir at relURL (e.g.
+// "videos/scene_1.mp4") so the file server can serve it, returning its
+// /static/ URL. The copy is swapped in atomically, so a player never reads a
+// half-written file, and takes the source's modification time so an
+// unchanged source (same size and time) isn't copied again.
+func (s *Server) publishToStatic(srcPath, relURL string) (string, error) {
+	cleaned := path.Clean("/" + relURL)[1:]
+	if cleaned == "" {
+		return "", fmt.Errorf("invalid static path %q", relURL)
+	}
+	relURL = cleaned
+	dstPath := filepath.Join(s.StaticDir, filepath.FromSlash(relURL))
+
+	staticURL := "/static/" + relURL
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	srcInfo, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+	if dstInfo, err := os.Stat(dstPath); err == nil && dstInfo.Size() == srcInfo.Size() && dstInfo.ModTime().Equal(srcInfo.ModTime()) {
+		return staticURL, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return "", err
+	}
+	if _, err := writeFileAtomic(dstPath, src, 0644); err != nil {
+		return "", fmt.Errorf("publish %s: %w", relURL, err)
+	}
+	if err := os.Chtimes(dstPath, time.Time{}, srcInfo.ModTime()); err != nil {
+		return "", err
+	}
+	return staticURL, nil
+}
@@ -0,0 +1,201 @@
+package srv
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// staleTempAge is how old a video-maker temp dir must be before it is
+// reported as a cleanup candidate; younger ones may belong to a running render.
+const staleTempAge = 24 * time.Hour
+
+// tempDirPrefix prefixes every scratch directory the server creates under
+// os.TempDir().
+const tempDirPrefix = "video-maker"
+
+type DirUsage struct {
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+type ProjectUsage struct {
+	ID    string `json:"id"`
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+type CleanupCandidate struct {
+	Kind    string    `json:"kind"` // "orphaned-video" or "stale-temp"
+	Path    string    `json:"path"`
+	Bytes   int64     `json:"bytes"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// dirSize sums the sizes of regular files under root. A missing root is empty.
+func dirSize(root string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// referencedStaticVideos collects the static video filenames that some
+// project still points at, either on disk or in memory.
+func (s *Server) referencedStaticVideos(projectDirs []string) map[string]bool {
+	refs := make(map[string]bool)
+	for _, dir := range projectDirs {
+		// Loading a project publishes its videos/ files under the same name
+		if entries, err := os.ReadDir(filepath.Join(dir, "videos")); err == nil {
+			for _, entry := range entries {
+				refs[entry.Name()] = true
+			}
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, "project.json"))
+		if err != nil {
+			continue
+		}
+		var project struct {
+			Scenes []struct {
+				VideoURL string `json:"videoUrl"`
+			} `json:"scenes"`
+		}
+		if json.Unmarshal(data, &project) != nil {
+			continue
+		}
+		for _, scene := range project.Scenes {
+			if name, ok := strings.CutPrefix(scene.VideoURL, "/static/videos/"); ok {
+				refs[path.Base(name)] = true
+			}
+		}
+	}
+
+	s.mu.RLock()
+	for _, project := range s.projects {
+		for _, scene := range project.Scenes {
+			if name, ok := strings.CutPrefix(scene.VideoURL, "/static/videos/"); ok {
+				refs[path.Base(name)] = true
+			}
+		}
+	}
+	s.mu.RUnlock()
+	return refs
+}
+
+// HandleStorage reports disk usage under ProjectsRoot and StaticDir, broken
+// down by project, along with orphaned static videos and stale temp dirs
+// that could be deleted.
+func (s *Server) HandleStorage(w http.ResponseWriter, r *http.Request) {
+	var projects []ProjectUsage
+	var projectDirs []string
+	entries, err := os.ReadDir(s.ProjectsRoot)
+	if err != nil && !os.IsNotExist(err) {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read projects root: "+err.Error())
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(s.ProjectsRoot, entry.Name())
+		size, err := dirSize(dir)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to measure project: "+err.Error())
+			return
+		}
+		projects = append(projects, ProjectUsage{ID: entry.Name(), Path: dir, Bytes: size})
+		projectDirs = append(projectDirs, dir)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Bytes > projects[j].Bytes })
+
+	projectsSize, err := dirSize(s.ProjectsRoot)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to measure projects root: "+err.Error())
+		return
+	}
+	staticSize, err := dirSize(s.StaticDir)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to measure static dir: "+err.Error())
+		return
+	}
+
+	var candidates []CleanupCandidate
+	referenced := s.referencedStaticVideos(projectDirs)
+	staticVideosDir := filepath.Join(s.StaticDir, "videos")
+	if entries, err := os.ReadDir(staticVideosDir); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() || referenced[entry.Name()] {
+				continue
+			}
+			if _, ok := videoMimeTypes[strings.ToLower(filepath.Ext(entry.Name()))]; !ok {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			candidates = append(candidates, CleanupCandidate{
+				Kind:    "orphaned-video",
+				Path:    filepath.Join(staticVideosDir, entry.Name()),
+				Bytes:   info.Size(),
+				ModTime: info.ModTime(),
+			})
+		}
+	}
+
+	if entries, err := os.ReadDir(os.TempDir()); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() || !strings.HasPrefix(entry.Name(), tempDirPrefix) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < staleTempAge {
+				continue
+			}
+			dir := filepath.Join(os.TempDir(), entry.Name())
+			size, _ := dirSize(dir)
+			candidates = append(candidates, CleanupCandidate{
+				Kind:    "stale-temp",
+				Path:    dir,
+				Bytes:   size,
+				ModTime: info.ModTime(),
+			})
+		}
+	}
+
+	var cleanupBytes int64
+	for _, c := range candidates {
+		cleanupBytes += c.Bytes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"totalBytes":        projectsSize + staticSize,
+		"projectsRoot":      DirUsage{Path: s.ProjectsRoot, Bytes: projectsSize},
+		"staticDir":         DirUsage{Path: s.StaticDir, Bytes: staticSize},
+		"projects":          projects,
+		"cleanupCandidates": candidates,
+		"cleanupBytes":      cleanupBytes,
+	})
+}
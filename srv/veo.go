@@ -0,0 +1,227 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	veoEndpoint     = "https://generativelanguage.googleapis.com/v1beta"
+	veoModel        = "veo-3.0-generate-preview"
+	veoPollInterval = 10 * time.Second
+	// veoTimeout bounds a single clip, including queueing on Google's side
+	veoTimeout = 10 * time.Minute
+	maxVeoClip = 200 << 20
+	// minVeoDuration and maxVeoDuration bound the clip length Veo accepts
+	minVeoDuration = 4
+	maxVeoDuration = 8
+)
+
+// VeoClient generates scene clips with Veo 3 through the Gemini API's
+// long-running predict operation.
+type VeoClient struct {
+	APIKey string
+	// Endpoint overrides the Gemini API base URL (for tests)
+	Endpoint     string
+	PollInterval time.Duration
+	Client       *http.Client
+}
+
+// VeoFrame is an image sent to Veo as the first or last frame of a clip.
+type VeoFrame struct {
+	Data     []byte
+	MimeType string
+}
+
+func (c *VeoClient) endpoint() string {
+	if c.Endpoint != "" {
+		return strings.TrimSuffix(c.Endpoint, "/")
+	}
+	return veoEndpoint
+}
+
+func (c *VeoClient) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return &http.Client{Timeout: 2 * time.Minute}
+}
+
+// do sends a Gemini API request and decodes the JSON response into out,
+// turning API errors into Go errors.
+func (c *VeoClient) do(ctx context.Context, method, url string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-goog-api-key", c.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("veo request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&apiErr)
+		return fmt.Errorf("veo: %s (status %d)", apiErr.Error.Message, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return fmt.Errorf("veo response: %w", err)
+	}
+	return nil
+}
+
+// veoClipDuration fits a requested clip length to the 4-8s Veo renders; the
+// clip's real length is probed once it's downloaded.
+func veoClipDuration(seconds int) int {
+	return min(max(seconds, minVeoDuration), maxVeoDuration)
+}
+
+// GenerateClip animates startFrame (towards endFrame, if given) following
+// the prompt, waits for the operation to finish and returns the MP4 bytes.
+// A positive durationSeconds asks for a clip of about that length.
+func (c *VeoClient) GenerateClip(ctx context.Context, prompt string, startFrame VeoFrame, endFrame *VeoFrame, durationSeconds int) ([]byte, error) {
+	if c.APIKey == "" {
+		return nil, errors.New("GEMINI_API_KEY is not set")
+	}
+	ctx, cancel := context.WithTimeout(ctx, veoTimeout)
+	defer cancel()
+
+	instance := map[string]any{
+		"prompt": prompt,
+		"image": map[string]string{
+			"bytesBase64Encoded": base64.StdEncoding.EncodeToString(startFrame.Data),
+			"mimeType":           startFrame.MimeType,
+		},
+	}
+	if endFrame != nil {
+		instance["lastFrame"] = map[string]string{
+			"bytesBase64Encoded": base64.StdEncoding.EncodeToString(endFrame.Data),
+			"mimeType":           endFrame.MimeType,
+		}
+	}
+
+	var op struct {
+		Name  string `json:"name"`
+		Done  bool   `json:"done"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Response struct {
+			GenerateVideoResponse struct {
+				GeneratedSamples []struct {
+					Video struct {
+						URI string `json:"uri"`
+					} `json:"video"`
+				} `json:"generatedSamples"`
+			} `json:"generateVideoResponse"`
+		} `json:"response"`
+	}
+	submitURL := fmt.Sprintf("%s/models/%s:predictLongRunning", c.endpoint(), veoModel)
+	body := map[string]any{"instances": []any{instance}}
+	if durationSeconds > 0 {
+		body["parameters"] = map[string]any{"durationSeconds": veoClipDuration(durationSeconds)}
+	}
+	if err := c.do(ctx, "POST", submitURL, body, &op); err != nil {
+		return nil, err
+	}
+	if op.Name == "" {
+		return nil, errors.New("veo: no operation returned")
+	}
+
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = veoPollInterval
+	}
+	opURL := c.endpoint() + "/" + op.Name
+	for !op.Done {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("veo: waiting for %s: %w", op.Name, ctx.Err())
+		case <-time.After(interval):
+		}
+		if err := c.do(ctx, "GET", opURL, nil, &op); err != nil {
+			return nil, err
+		}
+	}
+	if op.Error != nil {
+		return nil, fmt.Errorf("veo: %s", op.Error.Message)
+	}
+	samples := op.Response.GenerateVideoResponse.GeneratedSamples
+	if len(samples) == 0 || samples[0].Video.URI == "" {
+		return nil, errors.New("veo: no video returned")
+	}
+
+	// The file URI needs the API key too, so the clip is downloaded here
+	// rather than handed to the browser
+	req, err := http.NewRequestWithContext(ctx, "GET", samples[0].Video.URI, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-goog-api-key", c.APIKey)
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("veo download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("veo download: status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxVeoClip))
+}
+
+// readFrame loads a clip frame from a data URL, a /static/ path or a remote URL.
+func (s *Server) readFrame(ctx context.Context, frameURL string) (VeoFrame, error) {
+	switch {
+	case frameURL == "":
+		return VeoFrame{}, errors.New("frame is required")
+	case strings.HasPrefix(frameURL, "data:"):
+		mimeType, data, err := decodeDataURL(frameURL)
+		if err != nil {
+			return VeoFrame{}, err
+		}
+		return VeoFrame{Data: data, MimeType: mimeType}, nil
+	case strings.HasPrefix(frameURL, "/static/"):
+		framePath, _ := s.staticFilePath(frameURL)
+		data, err := os.ReadFile(framePath)
+		if err != nil {
+			return VeoFrame{}, err
+		}
+		return VeoFrame{Data: data, MimeType: http.DetectContentType(data)}, nil
+	default:
+		resp, err := s.httpGet(ctx, frameURL)
+		if err != nil {
+			return VeoFrame{}, fmt.Errorf("fetch frame: %w", err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 50<<20))
+		if err != nil {
+			return VeoFrame{}, err
+		}
+		return VeoFrame{Data: data, MimeType: http.DetectContentType(data)}, nil
+	}
+}
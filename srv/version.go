@@ -0,0 +1,84 @@
+package srv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// projectVersionKey is the field of project.json and videoedit.vproj that
+// counts saves. Each save bumps it; a save based on an older version is
+// refused so two tabs can't silently overwrite each other's edits.
+const projectVersionKey = "version"
+
+// savedVersion returns the version recorded in a saved project or editor
+// file, or 0 if the file doesn't exist or predates versioning.
+func savedVersion(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	var saved map[string]any
+	if err := json.Unmarshal(data, &saved); err != nil {
+		// A corrupt file has nothing worth protecting; let the save replace it
+		return 0, nil
+	}
+	if v, ok := saved[projectVersionKey].(float64); ok {
+		return int64(v), nil
+	}
+	return 0, nil
+}
+
+// baseVersion is the version a save was based on: the request's baseVersion
+// field, else its If-Match header ("3" or 3). It reports false when neither
+// is given.
+func baseVersion(r *http.Request, field *int64) (int64, bool, error) {
+	if field != nil {
+		return *field, true, nil
+	}
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("If-Match must be a project version, got %q", ifMatch)
+	}
+	return v, true, nil
+}
+
+// checkVersion compares a save's base version with the version of the file
+// at path and returns the version the save should record. On a mismatch it
+// writes a 409 carrying the current version, so the client can reload and
+// merge, and returns false. A save without a base version may only create
+// the file or replace one that predates versioning; over a versioned file
+// it gets a 428, since it could be overwriting anything.
+func checkVersion(w http.ResponseWriter, r *http.Request, path string, field *int64) (int64, bool) {
+	current, err := savedVersion(path)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read saved project: "+err.Error())
+		return 0, false
+	}
+	base, ok, err := baseVersion(r, field)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return 0, false
+	}
+	if !ok && current > 0 {
+		msg := fmt.Sprintf("%s is at version %d; load it and send its version as baseVersion to save over it", filepath.Base(path), current)
+		writeJSONErrorFields(w, http.StatusPreconditionRequired, msg, map[string]any{"version": current})
+		return 0, false
+	}
+	if ok && base != current {
+		msg := fmt.Sprintf("%s was saved elsewhere since version %d; reload to merge the changes", filepath.Base(path), base)
+		writeJSONErrorFields(w, http.StatusConflict, msg, map[string]any{"version": current})
+		return 0, false
+	}
+	return current + 1, true
+}